@@ -1,72 +1,297 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"net/http"
 	"os"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/Higor-ViniciusDev/posgo_raterlimite/configuration/database"
 	"github.com/Higor-ViniciusDev/posgo_raterlimite/configuration/logger"
+	"github.com/Higor-ViniciusDev/posgo_raterlimite/configuration/policy"
 	"github.com/Higor-ViniciusDev/posgo_raterlimite/internal/infra/api/controller"
+	"github.com/Higor-ViniciusDev/posgo_raterlimite/internal/infra/api/gateway"
 	"github.com/Higor-ViniciusDev/posgo_raterlimite/internal/infra/api/web"
 	"github.com/Higor-ViniciusDev/posgo_raterlimite/internal/infra/repository"
+	"github.com/Higor-ViniciusDev/posgo_raterlimite/internal/internal_error"
+	"github.com/Higor-ViniciusDev/posgo_raterlimite/internal/keyresolver"
 	"github.com/Higor-ViniciusDev/posgo_raterlimite/internal/middleware"
 	"github.com/Higor-ViniciusDev/posgo_raterlimite/internal/ratelimiter"
+	"github.com/Higor-ViniciusDev/posgo_raterlimite/internal/usecase/access_list_usecase"
 	"github.com/Higor-ViniciusDev/posgo_raterlimite/internal/usecase/expire_usecase"
 	"github.com/Higor-ViniciusDev/posgo_raterlimite/internal/usecase/policy_usecase"
+	"github.com/Higor-ViniciusDev/posgo_raterlimite/internal/usecase/ratelimit_usecase"
 	"github.com/Higor-ViniciusDev/posgo_raterlimite/internal/usecase/strategy_usecase"
 	"github.com/Higor-ViniciusDev/posgo_raterlimite/internal/usecase/tolken_usecase"
 	"github.com/joho/godotenv"
 	"github.com/redis/go-redis/v9"
 )
 
+// Arquivo com as variáveis de ambiente da aplicação
+const envFile = "cmd/ratelimite/.env"
+
 func main() {
 	defer logger.GetLogger().Sync()
 
-	if err := godotenv.Load("cmd/ratelimite/.env"); err != nil {
+	if err := godotenv.Load(envFile); err != nil {
 		logger.Error("Erro ao carregar variaveis de ambiente", err)
 		return
 	}
 
+	// Política de rate limit: POLICY_FILE (YAML/JSON) ou as variáveis do .env, validadas na inicialização
+	policyFile, err := loadPolicy()
+	if err != nil {
+		logger.Error("Politica de rate limit invalida", err)
+		return
+	}
+
+	// IP do cliente: só acredita nos headers de proxy vindos dos saltos confiáveis
+	clientIP, err := newClientIPResolver()
+	if err != nil {
+		logger.Error("Configuracao do IP do cliente invalida", err)
+		return
+	}
+	keyresolver.SetClientIPResolver(clientIP)
+
 	redis := database.NewConnectionRedis()
 
-	tolkenController, policyUsecase := initDependeces(redis)
+	tolkenController, policyUsecase, applyPolicy := initDependeces(redis, policyFile)
 
-	wokers := os.Getenv("WORKER_POOL_SIZE")
-	bufferSize := os.Getenv("SIZE_BUFFER_CHANNEL")
+	// Recarrega a política no SIGHUP e quando o arquivo muda, sem perder os contadores
+	policyWatcher, err := newPolicyWatcher(policyFile, applyPolicy)
+	if err != nil {
+		logger.Error("Intervalo de recarga da politica invalido", err)
+		return
+	}
+	go policyWatcher.Start(context.Background())
 
-	wokerNumber, _ := strconv.Atoi(wokers)
-	bufSizeNumber, _ := strconv.Atoi(bufferSize)
+	// Listas de IP/CIDR (allow passa sem rate limit, deny recebe 403), compartilhadas pelo Redis
+	accessListUsecase, err := newAccessList(redis)
+	if err != nil {
+		logger.Error("Intervalo de atualizacao das listas de IP invalido", err)
+		return
+	}
 
 	// RateLimiter
-	raterLimite := ratelimiter.NewRateLimiter(wokerNumber, bufSizeNumber)
+	raterLimite, err := newLimiter(redis)
+	if err != nil {
+		logger.Error("Configuracao do rate limiter invalida", err)
+		return
+	}
+	// Requisições em andamento por key (MAX_IN_FLIGHT_*)
+	concurrencyLimiter := ratelimiter.NewConcurrencyLimiter(0)
 
 	webServerPort := os.Getenv("WEB_SERVER_PORTA")
 	webServer := web.NovoWebServer(fmt.Sprintf(":%v", webServerPort))
 
 	webServer.RegistrarRota("/tolken", tolkenController.CreateTolken, "POST")
-	webServer.RegistrarRota("/", func(w http.ResponseWriter, r *http.Request) {
-		w.WriteHeader(http.StatusOK)
-	}, "GET", middleware.RateLimiterMiddleware(&policyUsecase, raterLimite))
+
+	// Decisão do rate limiter para serviços que não usam o middleware, só registrada com SERVICE_API_KEY
+	if serviceKey := os.Getenv("SERVICE_API_KEY"); serviceKey != "" {
+		rateLimitController := controller.NewRateLimitController(ratelimit_usecase.NewRateLimitUsecase(policyUsecase, raterLimite))
+		serviceMiddleware := middleware.ServiceKeyMiddleware(serviceKey)
+		webServer.RegistrarRota("/v1/ratelimit/check", rateLimitController.Check, "POST", serviceMiddleware)
+		webServer.RegistrarRota("/v1/ratelimit/check/batch", rateLimitController.CheckBatch, "POST", serviceMiddleware)
+	}
+
+	// Administração das listas de IP e dos tolkens com plano, só registrada com ADMIN_API_KEY
+	if adminKey := os.Getenv("ADMIN_API_KEY"); adminKey != "" {
+		accessListController := controller.NewAccessListController(accessListUsecase)
+		adminMiddleware := middleware.AdminKeyMiddleware(adminKey)
+		webServer.RegistrarRota("/admin/tolken", tolkenController.CreateTolkenAdmin, "POST", adminMiddleware)
+		webServer.RegistrarRota("/admin/access-list", accessListController.List, "GET", adminMiddleware)
+		webServer.RegistrarRota("/admin/access-list", accessListController.Add, "POST", adminMiddleware)
+		webServer.RegistrarRota("/admin/access-list", accessListController.Remove, "DELETE", adminMiddleware)
+	}
+
+	// a lista de IP é consultada só no primeiro middleware, o IP liberado também passa pelo segundo sem limite
+	limiterMiddlewares := []func(http.Handler) http.Handler{
+		middleware.RateLimiterMiddleware(policyUsecase, raterLimite, append(rateLimiterOptions(), middleware.WithAccessList(accessListUsecase))...),
+		middleware.ConcurrencyLimiterMiddleware(policyUsecase, concurrencyLimiter, rateLimiterOptions()...),
+	}
+
+	// Modo gateway: o rate limiter fica na frente do upstream e encaminha o tráfego liberado
+	if upstream := os.Getenv("GATEWAY_UPSTREAM_URL"); upstream != "" {
+		timeout, err := envInt("GATEWAY_TIMEOUT")
+		if err != nil {
+			logger.Error("Timeout do gateway invalido", err)
+			return
+		}
+
+		proxy, err := gateway.NewReverseProxy(upstream, time.Duration(timeout)*time.Second)
+		if err != nil {
+			logger.Error("Erro ao configurar gateway", err)
+			return
+		}
+
+		webServer.RegistrarRota("/*", proxy.ServeHTTP, "", limiterMiddlewares...)
+	} else {
+		webServer.RegistrarRota("/", func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusOK)
+		}, "GET", limiterMiddlewares...)
+	}
+
 	webServer.IniciarWebServer()
 }
 
-func initDependeces(redisCli *redis.Client) (controller.TolkenController, policy_usecase.PolicyUsecase) {
+// initDependeces também devolve a função que aplica uma política recarregada nas strategies
+func initDependeces(redisCli *redis.Client, policyFile *policy.File) (controller.TolkenController, *policy_usecase.PolicyUsecase, func(*policy.File)) {
 	var tolkenController controller.TolkenController
 
 	expirerUsecase := expire_usecase.NewDefaultExpirer()
 
 	requestInfoRepository := repository.NewRequestInfoRepository(redisCli)
-	//Tolken dependeces
 	tolkeRepository := repository.NewTolkenDB(redisCli)
-	tolkenUsecase := tolken_usecase.NewTolkenUsecase(tolkeRepository, expirerUsecase)
+
+	ipStrategy := strategy_usecase.NewIPStrategyUsecaseWithLimits(policyFile.Strategies.IP.Limits(), requestInfoRepository)
+	tokenStrategy := strategy_usecase.NewTokenStrategyUsecaseWithLimits(policyFile.Strategies.Tolken.Limits(), tolkeRepository, requestInfoRepository)
+
+	policyUsecase := policy_usecase.NewPolicyUsecase(ipStrategy, tokenStrategy)
+	policyUsecase.Routes = policyFile.RoutePolicies()
+	policyUsecase.Chain = policyFile.PolicyChain()
+	policyUsecase.TolkenFallback = policyFile.TolkenFallbackRules()
+
+	//Tolken dependeces: o plano pedido na criação precisa existir na política em uso
+	tolkenUsecase := tolken_usecase.NewTolkenUsecase(tolkeRepository, expirerUsecase, policyUsecase)
 	tolkenController = *controller.NewTolkenController(tolkenUsecase)
 
-	ipStrategy := strategy_usecase.NewIPStrategyUsecase(requestInfoRepository)
-	tokenStrategy := strategy_usecase.NewTokenStrategyUsecase(tolkeRepository, requestInfoRepository)
+	applyPolicy := func(file *policy.File) {
+		policyUsecase.Reload(
+			strategy_usecase.NewIPStrategyUsecaseWithLimits(file.Strategies.IP.Limits(), requestInfoRepository),
+			strategy_usecase.NewTokenStrategyUsecaseWithLimits(file.Strategies.Tolken.Limits(), tolkeRepository, requestInfoRepository),
+			file.RoutePolicies(),
+			file.PolicyChain(),
+			file.TolkenFallbackRules(),
+		)
+	}
+
+	return tolkenController, policyUsecase, applyPolicy
+}
 
-	policyUsecase := *policy_usecase.NewPolicyUsecase(ipStrategy, tokenStrategy)
+// loadPolicy lê o arquivo de POLICY_FILE e, sem ele, monta a política pelas variáveis de ambiente
+// (REQUEST_PER_SECOND_*, TLL_KEY_*, ROUTE_POLICIES...). Qualquer valor inválido impede a inicialização.
+func loadPolicy() (*policy.File, *internal_error.InternalError) {
+	if path := os.Getenv("POLICY_FILE"); path != "" {
+		return policy.Load(path)
+	}
+	return policy.FromEnv()
+}
 
-	return tolkenController, policyUsecase
+// newPolicyWatcher observa o POLICY_FILE ou, sem ele, o .env (relido com godotenv.Overload).
+// POLICY_RELOAD_INTERVAL são os segundos entre as verificações do arquivo (0 deixa só o SIGHUP).
+func newPolicyWatcher(current *policy.File, apply func(*policy.File)) (*policy.Watcher, *internal_error.InternalError) {
+	interval := policy.DefaultReloadInterval
+	if os.Getenv("POLICY_RELOAD_INTERVAL") != "" {
+		seconds, err := envInt("POLICY_RELOAD_INTERVAL")
+		if err != nil {
+			return nil, err
+		}
+		interval = time.Duration(seconds) * time.Second
+	}
+
+	path := os.Getenv("POLICY_FILE")
+	if path == "" {
+		path = envFile
+	}
+
+	return policy.NewWatcher(path, current, interval, func() (*policy.File, *internal_error.InternalError) {
+		if os.Getenv("POLICY_FILE") == "" {
+			if err := godotenv.Overload(envFile); err != nil {
+				return nil, internal_error.NewBadRequestError("policy env: " + err.Error())
+			}
+		}
+		return loadPolicy()
+	}, apply), nil
+}
+
+// rateLimiterOptions liga os campos RateLimit/RateLimit-Policy da IETF com RATE_LIMIT_IETF_HEADERS=true
+// e os erros em application/problem+json com RATE_LIMIT_ERROR_FORMAT=problem.
+func rateLimiterOptions() []middleware.RateLimiterOption {
+	var opts []middleware.RateLimiterOption
+	if ietf, _ := strconv.ParseBool(os.Getenv("RATE_LIMIT_IETF_HEADERS")); ietf {
+		opts = append(opts, middleware.WithIETFHeaders())
+	}
+	if os.Getenv("RATE_LIMIT_ERROR_FORMAT") == "problem" {
+		opts = append(opts, middleware.WithProblemJSON())
+	}
+	return opts
+}
+
+// newClientIPResolver lê CLIENT_IP_STRATEGY (remote_addr | trusted_proxies | rightmost_non_private | header),
+// TRUSTED_PROXIES (IPs/CIDRs separados por vírgula) e CLIENT_IP_HEADER
+func newClientIPResolver() (*keyresolver.ClientIPResolver, *internal_error.InternalError) {
+	return keyresolver.NewClientIPResolver(keyresolver.ClientIPConfig{
+		Strategy:       os.Getenv("CLIENT_IP_STRATEGY"),
+		TrustedProxies: strings.Split(os.Getenv("TRUSTED_PROXIES"), ","),
+		Header:         os.Getenv("CLIENT_IP_HEADER"),
+	})
+}
+
+// newAccessList lê as listas antes de atender e depois as relê do Redis em segundo plano
+// a cada ACCESS_LIST_REFRESH_INTERVAL segundos (vazio usa o padrão)
+func newAccessList(redisCli *redis.Client) (*access_list_usecase.AccessListUsecase, *internal_error.InternalError) {
+	seconds, err := envInt("ACCESS_LIST_REFRESH_INTERVAL")
+	if err != nil {
+		return nil, err
+	}
+	accessList := access_list_usecase.NewAccessListUsecase(repository.NewAccessListRepository(redisCli), time.Duration(seconds)*time.Second)
+
+	accessList.Refresh(context.Background())
+	go accessList.Start(context.Background())
+	return accessList, nil
+}
+
+// newLimiter escolhe a implementação do Limiter.
+// RATE_LIMITER_STORE=redis compartilha os contadores entre as réplicas e
+// RATE_LIMITER_MODE=direct decide na goroutine da requisição, sem o pool de workers.
+// MEMORY_SHARDS define em quantos shards os contadores em memória são divididos (0 acompanha os núcleos).
+func newLimiter(redisCli *redis.Client) (ratelimiter.Limiter, *internal_error.InternalError) {
+	shards, err := envInt("MEMORY_SHARDS")
+	if err != nil {
+		return nil, err
+	}
+
+	useRedis := os.Getenv("RATE_LIMITER_STORE") == "redis"
+
+	if os.Getenv("RATE_LIMITER_MODE") == "direct" {
+		if useRedis {
+			return ratelimiter.NewRedisLimiter(redisCli), nil
+		}
+		return ratelimiter.NewLocalLimiter(shards), nil
+	}
+
+	wokerNumber, err := envInt("WORKER_POOL_SIZE")
+	if err != nil {
+		return nil, err
+	}
+	// sem workers nenhuma mensagem do pool seria atendida
+	if wokerNumber == 0 {
+		return nil, internal_error.NewBadRequestError("WORKER_POOL_SIZE deve ser maior que zero")
+	}
+	bufSizeNumber, err := envInt("SIZE_BUFFER_CHANNEL")
+	if err != nil {
+		return nil, err
+	}
+
+	if useRedis {
+		return ratelimiter.NewRedisRateLimiter(redisCli, wokerNumber, bufSizeNumber), nil
+	}
+	return ratelimiter.NewShardedRateLimiter(wokerNumber, bufSizeNumber, shards), nil
+}
+
+// envInt lê a variável como inteiro não negativo, vazia vale 0. Valor inválido impede a inicialização.
+func envInt(name string) (int, *internal_error.InternalError) {
+	value := os.Getenv(name)
+	if value == "" {
+		return 0, nil
+	}
+
+	number, err := strconv.Atoi(value)
+	if err != nil || number < 0 {
+		return 0, internal_error.NewBadRequestError(fmt.Sprintf("%s invalido: %q", name, value))
+	}
+	return number, nil
 }
@@ -0,0 +1,84 @@
+package policy
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+)
+
+// Diff lista os campos que mudaram entre as duas políticas, um por linha e em ordem
+// (ex: "strategies.ip.limit: 5 -> 10"). Campo que só existe em uma delas aparece como "-".
+func Diff(previous *File, next *File) []string {
+	before := flatten(previous)
+	after := flatten(next)
+
+	fields := make(map[string]struct{}, len(before)+len(after))
+	for field := range before {
+		fields[field] = struct{}{}
+	}
+	for field := range after {
+		fields[field] = struct{}{}
+	}
+
+	var changes []string
+	for field := range fields {
+		oldValue, hadOld := before[field]
+		newValue, hasNew := after[field]
+		if hadOld && hasNew && oldValue == newValue {
+			continue
+		}
+		if !hadOld {
+			oldValue = "-"
+		}
+		if !hasNew {
+			newValue = "-"
+		}
+		changes = append(changes, fmt.Sprintf("%s: %s -> %s", field, oldValue, newValue))
+	}
+
+	sort.Strings(changes)
+	return changes
+}
+
+// flatten converte a política em caminho -> valor, com os mesmos nomes de campo do arquivo
+func flatten(file *File) map[string]string {
+	values := make(map[string]string)
+	if file == nil {
+		return values
+	}
+
+	data, err := json.Marshal(file)
+	if err != nil {
+		return values
+	}
+
+	var tree interface{}
+	if err := json.Unmarshal(data, &tree); err != nil {
+		return values
+	}
+
+	flattenValue("", tree, values)
+	return values
+}
+
+func flattenValue(path string, value interface{}, values map[string]string) {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, child := range v {
+			if path == "" {
+				flattenValue(key, child, values)
+			} else {
+				flattenValue(path+"."+key, child, values)
+			}
+		}
+	case []interface{}:
+		for i, child := range v {
+			flattenValue(fmt.Sprintf("%s[%d]", path, i), child, values)
+		}
+	case nil:
+		// lista vazia não conta como campo
+	default:
+		data, _ := json.Marshal(v)
+		values[path] = string(data)
+	}
+}
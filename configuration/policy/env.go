@@ -0,0 +1,152 @@
+package policy
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Higor-ViniciusDev/posgo_raterlimite/internal/entity/limit_entity"
+	"github.com/Higor-ViniciusDev/posgo_raterlimite/internal/internal_error"
+)
+
+// Sufixos das variáveis de ambiente de cada strategy
+const (
+	EnvIP     = "IP"
+	EnvTolken = "TOLKEN"
+)
+
+// FromEnv monta a política pelas variáveis de ambiente (REQUEST_PER_SECOND_*, TLL_KEY_*, ROUTE_POLICIES, POLICY_CHAIN, INVALID_TOLKEN_FALLBACK...),
+// para quem não usa POLICY_FILE. Passa pela mesma validação do arquivo.
+func FromEnv() (*File, *internal_error.InternalError) {
+	var file File
+	var problems []string
+
+	var err *internal_error.InternalError
+	if file.Strategies.IP, err = StrategyFromEnv(EnvIP); err != nil {
+		problems = append(problems, err.Message)
+	}
+	if file.Strategies.Tolken, err = StrategyFromEnv(EnvTolken); err != nil {
+		problems = append(problems, err.Message)
+	}
+
+	routes, err := limit_entity.ParseRoutePolicies(os.Getenv("ROUTE_POLICIES"))
+	if err != nil {
+		problems = append(problems, "ROUTE_POLICIES: "+err.Message)
+	}
+	for _, route := range routes {
+		file.Routes = append(file.Routes, Route{
+			Method:    route.Method,
+			Path:      route.Path,
+			Limit:     route.Limit,
+			Window:    Duration(route.TTL),
+			Penalty:   Duration(route.Penalty),
+			Algorithm: string(route.Algorithm),
+			Burst:     route.Burst,
+		})
+	}
+
+	chain, err := limit_entity.ParseChain(os.Getenv("POLICY_CHAIN"))
+	if err != nil {
+		problems = append(problems, "POLICY_CHAIN: "+err.Message)
+	}
+	file.Chain = chain
+
+	fallbacks, err := limit_entity.ParseTolkenFallbackRules(os.Getenv("INVALID_TOLKEN_FALLBACK"))
+	if err != nil {
+		problems = append(problems, "INVALID_TOLKEN_FALLBACK: "+err.Message)
+	}
+	for _, rule := range fallbacks {
+		file.InvalidTolken = append(file.InvalidTolken, TolkenFallback{Method: rule.Method, Path: rule.Path, Fallback: string(rule.Fallback)})
+	}
+
+	if len(problems) > 0 {
+		return nil, internal_error.NewBadRequestError("policy env invalid: " + strings.Join(problems, "; "))
+	}
+	if validationErr := file.Validate(); validationErr != nil {
+		return nil, internal_error.NewBadRequestError("policy env: " + validationErr.Message)
+	}
+	return &file, nil
+}
+
+// StrategyFromEnv lê as variáveis da strategy com o sufixo informado (IP ou TOLKEN). Variáveis vazias ficam zeradas;
+// o erro lista as que não puderam ser convertidas. Não valida os limites, isso fica com File.Validate.
+func StrategyFromEnv(suffix string) (Strategy, *internal_error.InternalError) {
+	env := envReader{suffix: suffix}
+
+	strategy := Strategy{
+		Limit:           env.int("REQUEST_PER_SECOND"),
+		Window:          env.seconds("TLL_KEY"),
+		Penalty:         env.seconds("TIME_UNLOCKED_NEW_REQUEST"),
+		Algorithm:       os.Getenv("ALGORITHM_" + suffix),
+		Burst:           env.int("BURST"),
+		PenaltyLookback: env.seconds("PENALTY_LOOKBACK"),
+		MaxInFlight:     env.int("MAX_IN_FLIGHT"),
+		IPv4Prefix:      int(env.int("IPV4_PREFIX")),
+		IPv6Prefix:      int(env.int("IPV6_PREFIX")),
+		Key:             strings.TrimSpace(os.Getenv("KEY_EXTRACTOR_" + suffix)),
+	}
+
+	steps, err := limit_entity.ParsePenaltySteps(os.Getenv("PENALTY_STEPS_" + suffix))
+	env.check("PENALTY_STEPS", err)
+	for _, step := range steps {
+		strategy.PenaltySteps = append(strategy.PenaltySteps, Duration(step))
+	}
+
+	costs, err := limit_entity.ParseCostRules(os.Getenv("REQUEST_COST_" + suffix))
+	env.check("REQUEST_COST", err)
+	for _, cost := range costs {
+		strategy.Costs = append(strategy.Costs, Cost{Method: cost.Method, Path: cost.Path, Cost: cost.Cost})
+	}
+
+	windows, err := limit_entity.ParseWindows(os.Getenv("EXTRA_WINDOWS_" + suffix))
+	env.check("EXTRA_WINDOWS", err)
+	for _, window := range windows {
+		strategy.ExtraWindows = append(strategy.ExtraWindows, Window{Limit: window.Limit, Window: Duration(window.TTL)})
+	}
+
+	plans, err := limit_entity.ParsePlans(os.Getenv("PLANS_" + suffix))
+	env.check("PLANS", err)
+	for name, plan := range plans {
+		if strategy.Plans == nil {
+			strategy.Plans = make(map[string]Plan)
+		}
+		strategy.Plans[name] = Plan{Limit: plan.Limit, Window: Duration(plan.TTL), Penalty: Duration(plan.Penalty)}
+	}
+
+	if len(env.problems) > 0 {
+		return strategy, internal_error.NewBadRequestError(strings.Join(env.problems, "; "))
+	}
+	return strategy, nil
+}
+
+// envReader lê as variáveis de uma strategy guardando os erros de conversão
+type envReader struct {
+	suffix   string
+	problems []string
+}
+
+func (e *envReader) int(name string) int64 {
+	value := strings.TrimSpace(os.Getenv(name + "_" + e.suffix))
+	if value == "" {
+		return 0
+	}
+
+	parsed, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		e.problems = append(e.problems, fmt.Sprintf("%s_%s: %q is not a number", name, e.suffix, value))
+	}
+	return parsed
+}
+
+// seconds lê a variável como segundos inteiros, como as durações do .env sempre foram
+func (e *envReader) seconds(name string) Duration {
+	return Duration(time.Duration(e.int(name)) * time.Second)
+}
+
+func (e *envReader) check(name string, err *internal_error.InternalError) {
+	if err != nil {
+		e.problems = append(e.problems, fmt.Sprintf("%s_%s: %s", name, e.suffix, err.Message))
+	}
+}
@@ -0,0 +1,347 @@
+package policy
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/Higor-ViniciusDev/posgo_raterlimite/internal/entity/key_entity"
+	"github.com/Higor-ViniciusDev/posgo_raterlimite/internal/entity/limit_entity"
+	"github.com/Higor-ViniciusDev/posgo_raterlimite/internal/internal_error"
+	"gopkg.in/yaml.v3"
+)
+
+// File é a política de rate limit declarada em YAML ou JSON: os limites de cada strategy e os limites por rota.
+// Campos desconhecidos são recusados, um erro de digitação não pode virar limite 0.
+type File struct {
+	Strategies Strategies `yaml:"strategies" json:"strategies"`
+	Routes     []Route    `yaml:"routes" json:"routes"`
+	// Chain são as strategies verificadas juntas em cada requisição (ex: [tolken, ip]), vazia usa uma só
+	Chain []string `yaml:"chain" json:"chain"`
+	// InvalidTolken define por rota o que fazer com tolken inexistente ou expirado, sem regra recusa com 400
+	InvalidTolken []TolkenFallback `yaml:"invalid_tolken" json:"invalid_tolken"`
+}
+
+type Strategies struct {
+	IP     Strategy `yaml:"ip" json:"ip"`
+	Tolken Strategy `yaml:"tolken" json:"tolken"`
+}
+
+// Strategy são os limites de uma strategy. Limit e Window são obrigatórios, o resto é opcional.
+type Strategy struct {
+	Limit           int64      `yaml:"limit" json:"limit"`
+	Window          Duration   `yaml:"window" json:"window"`
+	ExtraWindows    []Window   `yaml:"extra_windows" json:"extra_windows"`
+	Algorithm       string     `yaml:"algorithm" json:"algorithm"`
+	Burst           int64      `yaml:"burst" json:"burst"`
+	Penalty         Duration   `yaml:"penalty" json:"penalty"`
+	PenaltySteps    []Duration `yaml:"penalty_steps" json:"penalty_steps"`
+	PenaltyLookback Duration   `yaml:"penalty_lookback" json:"penalty_lookback"`
+	Costs           []Cost     `yaml:"costs" json:"costs"`
+	MaxInFlight     int64      `yaml:"max_in_flight" json:"max_in_flight"`
+	// Plans só existem na strategy de tolken, cada tolken pode ser criado com um deles
+	Plans map[string]Plan `yaml:"plans" json:"plans"`
+	// IPv4Prefix e IPv6Prefix agrupam os IPs da mesma rede em uma key (só na strategy de ip, 0 usa o endereço completo)
+	IPv4Prefix int `yaml:"ipv4_prefix" json:"ipv4_prefix"`
+	IPv6Prefix int `yaml:"ipv6_prefix" json:"ipv6_prefix"`
+	// Key é de onde vem a identificação do cliente (ex: "bearer,query:api_key" ou "jwt:tenant_id"), vazio usa o padrão
+	Key string `yaml:"key" json:"key"`
+}
+
+// Plan troca os limites da strategy para os tolkens do plano, campos zerados mantêm o valor da strategy
+type Plan struct {
+	Limit        int64    `yaml:"limit" json:"limit"`
+	Window       Duration `yaml:"window" json:"window"`
+	Penalty      Duration `yaml:"penalty" json:"penalty"`
+	ExtraWindows []Window `yaml:"extra_windows" json:"extra_windows"`
+}
+
+type Window struct {
+	Limit  int64    `yaml:"limit" json:"limit"`
+	Window Duration `yaml:"window" json:"window"`
+}
+
+// Cost é o custo das requisições que casarem com o método (opcional) e o prefixo do caminho
+type Cost struct {
+	Method string `yaml:"method" json:"method"`
+	Path   string `yaml:"path" json:"path"`
+	Cost   int64  `yaml:"cost" json:"cost"`
+}
+
+// Route troca os limites da strategy nas requisições que casarem com o método (opcional) e o caminho
+type Route struct {
+	Method    string   `yaml:"method" json:"method"`
+	Path      string   `yaml:"path" json:"path"`
+	Limit     int64    `yaml:"limit" json:"limit"`
+	Window    Duration `yaml:"window" json:"window"`
+	Penalty   Duration `yaml:"penalty" json:"penalty"`
+	Algorithm string   `yaml:"algorithm" json:"algorithm"`
+	Burst     int64    `yaml:"burst" json:"burst"`
+}
+
+// TolkenFallback é o fallback (reject, ip ou unauthorized) das requisições que casarem com o método (opcional)
+// e o prefixo do caminho
+type TolkenFallback struct {
+	Method   string `yaml:"method" json:"method"`
+	Path     string `yaml:"path" json:"path"`
+	Fallback string `yaml:"fallback" json:"fallback"`
+}
+
+// Load lê e valida o arquivo de política. Arquivos .json são lidos como JSON, o resto como YAML.
+func Load(path string) (*File, *internal_error.InternalError) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, internal_error.NewBadRequestError(fmt.Sprintf("policy file %s: %v", path, err))
+	}
+
+	var file File
+	if strings.EqualFold(filepath.Ext(path), ".json") {
+		decoder := json.NewDecoder(bytes.NewReader(data))
+		decoder.DisallowUnknownFields()
+		err = decoder.Decode(&file)
+	} else {
+		decoder := yaml.NewDecoder(bytes.NewReader(data))
+		decoder.KnownFields(true)
+		err = decoder.Decode(&file)
+	}
+	if err != nil {
+		return nil, internal_error.NewBadRequestError(fmt.Sprintf("policy file %s: %v", path, err))
+	}
+
+	if validationErr := file.Validate(); validationErr != nil {
+		return nil, internal_error.NewBadRequestError(fmt.Sprintf("policy file %s: %s", path, validationErr.Message))
+	}
+	return &file, nil
+}
+
+// Validate confere a política inteira e devolve todos os problemas encontrados de uma vez
+func (f *File) Validate() *internal_error.InternalError {
+	var problems []string
+
+	problems = append(problems, f.Strategies.IP.validate("strategies.ip")...)
+	problems = append(problems, f.Strategies.Tolken.validate("strategies.tolken")...)
+	if len(f.Strategies.IP.Plans) > 0 {
+		problems = append(problems, "strategies.ip.plans is only supported on strategies.tolken")
+	}
+	if f.Strategies.Tolken.IPv4Prefix != 0 || f.Strategies.Tolken.IPv6Prefix != 0 {
+		problems = append(problems, "strategies.tolken.ipv4_prefix/ipv6_prefix are only supported on strategies.ip")
+	}
+	for i, route := range f.Routes {
+		problems = append(problems, route.validate(fmt.Sprintf("routes[%d]", i))...)
+	}
+	if _, err := limit_entity.ParseChain(strings.Join(f.Chain, ",")); err != nil {
+		problems = append(problems, "chain: "+err.Message)
+	}
+	for i, fallback := range f.InvalidTolken {
+		if _, err := limit_entity.ParseTolkenFallback(fallback.Fallback); err != nil || !strings.HasPrefix(fallback.Path, "/") {
+			problems = append(problems, fmt.Sprintf("invalid_tolken[%d] needs a path starting with / and fallback reject, ip or unauthorized", i))
+		}
+	}
+
+	if len(problems) > 0 {
+		return internal_error.NewBadRequestError("policy invalid: " + strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+func (s Strategy) validate(field string) []string {
+	var problems []string
+
+	if s.Limit <= 0 {
+		problems = append(problems, field+".limit must be greater than 0")
+	}
+	if s.Window <= 0 {
+		problems = append(problems, field+".window must be greater than 0")
+	}
+	for i, window := range s.ExtraWindows {
+		if window.Limit <= 0 || window.Window <= 0 {
+			problems = append(problems, fmt.Sprintf("%s.extra_windows[%d] needs limit and window greater than 0", field, i))
+		}
+	}
+	if _, err := limit_entity.ParseAlgorithm(s.Algorithm); err != nil {
+		problems = append(problems, fmt.Sprintf("%s.algorithm %q is unknown", field, s.Algorithm))
+	}
+	if s.Burst < 0 {
+		problems = append(problems, field+".burst must not be negative")
+	}
+	if s.Penalty < 0 {
+		problems = append(problems, field+".penalty must not be negative")
+	}
+	for i, step := range s.PenaltySteps {
+		if step <= 0 {
+			problems = append(problems, fmt.Sprintf("%s.penalty_steps[%d] must be greater than 0", field, i))
+		}
+	}
+	if s.PenaltyLookback < 0 {
+		problems = append(problems, field+".penalty_lookback must not be negative")
+	}
+	for i, cost := range s.Costs {
+		if !strings.HasPrefix(cost.Path, "/") || cost.Cost <= 0 {
+			problems = append(problems, fmt.Sprintf("%s.costs[%d] needs a path starting with / and cost greater than 0", field, i))
+		}
+	}
+	if s.MaxInFlight < 0 {
+		problems = append(problems, field+".max_in_flight must not be negative")
+	}
+	if _, err := key_entity.ParseKeyExtractor(s.Key); err != nil {
+		problems = append(problems, fmt.Sprintf("%s.key: %s", field, err.Message))
+	}
+	if s.IPv4Prefix < 0 || s.IPv4Prefix > 32 {
+		problems = append(problems, field+".ipv4_prefix must be between 0 and 32")
+	}
+	if s.IPv6Prefix < 0 || s.IPv6Prefix > 128 {
+		problems = append(problems, field+".ipv6_prefix must be between 0 and 128")
+	}
+
+	names := make([]string, 0, len(s.Plans))
+	for name := range s.Plans {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		problems = append(problems, s.Plans[name].validate(field+".plans."+name)...)
+	}
+
+	return problems
+}
+
+func (p Plan) validate(field string) []string {
+	var problems []string
+
+	if p.Limit < 0 {
+		problems = append(problems, field+".limit must not be negative")
+	}
+	if p.Window < 0 {
+		problems = append(problems, field+".window must not be negative")
+	}
+	if p.Penalty < 0 {
+		problems = append(problems, field+".penalty must not be negative")
+	}
+	for i, window := range p.ExtraWindows {
+		if window.Limit <= 0 || window.Window <= 0 {
+			problems = append(problems, fmt.Sprintf("%s.extra_windows[%d] needs limit and window greater than 0", field, i))
+		}
+	}
+
+	return problems
+}
+
+func (r Route) validate(field string) []string {
+	var problems []string
+
+	if !strings.HasPrefix(r.Path, "/") {
+		problems = append(problems, field+".path must start with /")
+	}
+	if r.Limit <= 0 {
+		problems = append(problems, field+".limit must be greater than 0")
+	}
+	if r.Window <= 0 {
+		problems = append(problems, field+".window must be greater than 0")
+	}
+	if r.Penalty < 0 {
+		problems = append(problems, field+".penalty must not be negative")
+	}
+	if r.Algorithm != "" {
+		if _, err := limit_entity.ParseAlgorithm(r.Algorithm); err != nil {
+			problems = append(problems, fmt.Sprintf("%s.algorithm %q is unknown", field, r.Algorithm))
+		}
+	}
+	if r.Burst < 0 {
+		problems = append(problems, field+".burst must not be negative")
+	}
+
+	return problems
+}
+
+// Limits converte a strategy já validada para os limites usados pela strategy_usecase
+func (s Strategy) Limits() limit_entity.StrategyLimits {
+	algorithm, _ := limit_entity.ParseAlgorithm(s.Algorithm)
+	extractor, _ := key_entity.ParseKeyExtractor(s.Key)
+
+	plans := make(map[string]limit_entity.Plan, len(s.Plans))
+	for name, plan := range s.Plans {
+		plans[name] = limit_entity.Plan{
+			Limit:        plan.Limit,
+			TTL:          time.Duration(plan.Window),
+			Penalty:      time.Duration(plan.Penalty),
+			ExtraWindows: entityWindows(plan.ExtraWindows),
+		}
+	}
+
+	steps := make([]time.Duration, 0, len(s.PenaltySteps))
+	for _, step := range s.PenaltySteps {
+		steps = append(steps, time.Duration(step))
+	}
+
+	costs := make(limit_entity.CostRules, 0, len(s.Costs))
+	for _, cost := range s.Costs {
+		costs = append(costs, limit_entity.CostRule{Method: strings.ToUpper(cost.Method), Path: cost.Path, Cost: cost.Cost})
+	}
+
+	return limit_entity.StrategyLimits{
+		Limit:         s.Limit,
+		TTL:           time.Duration(s.Window),
+		Penalty:       time.Duration(s.Penalty),
+		ExtraWindows:  entityWindows(s.ExtraWindows),
+		Algorithm:     algorithm,
+		Burst:         s.Burst,
+		PenaltyPolicy: limit_entity.NewPenaltyPolicy(steps, time.Duration(s.PenaltyLookback)),
+		CostRules:     costs,
+		MaxInFlight:   s.MaxInFlight,
+		Plans:         plans,
+		IPPrefix:      limit_entity.IPPrefix{IPv4: s.IPv4Prefix, IPv6: s.IPv6Prefix},
+		KeyExtractor:  extractor,
+	}
+}
+
+func entityWindows(windows []Window) []limit_entity.Window {
+	converted := make([]limit_entity.Window, 0, len(windows))
+	for _, window := range windows {
+		converted = append(converted, limit_entity.Window{Limit: window.Limit, TTL: time.Duration(window.Window)})
+	}
+	return converted
+}
+
+// PolicyChain converte a cadeia já validada
+func (f *File) PolicyChain() limit_entity.Chain {
+	chain, _ := limit_entity.ParseChain(strings.Join(f.Chain, ","))
+	return chain
+}
+
+// TolkenFallbackRules converte os fallbacks já validados, na mesma ordem do arquivo
+func (f *File) TolkenFallbackRules() limit_entity.TolkenFallbackRules {
+	rules := make(limit_entity.TolkenFallbackRules, 0, len(f.InvalidTolken))
+	for _, rule := range f.InvalidTolken {
+		fallback, _ := limit_entity.ParseTolkenFallback(rule.Fallback)
+		rules = append(rules, limit_entity.TolkenFallbackRule{Method: strings.ToUpper(rule.Method), Path: rule.Path, Fallback: fallback})
+	}
+	return rules
+}
+
+// RoutePolicies converte as rotas já validadas, na mesma ordem do arquivo
+func (f *File) RoutePolicies() limit_entity.RoutePolicies {
+	routes := make(limit_entity.RoutePolicies, 0, len(f.Routes))
+	for _, route := range f.Routes {
+		// sem algoritmo a rota mantém o da strategy
+		var algorithm limit_entity.Algorithm
+		if route.Algorithm != "" {
+			algorithm, _ = limit_entity.ParseAlgorithm(route.Algorithm)
+		}
+
+		routes = append(routes, limit_entity.RoutePolicy{
+			Method:    strings.ToUpper(route.Method),
+			Path:      route.Path,
+			Limit:     route.Limit,
+			TTL:       time.Duration(route.Window),
+			Penalty:   time.Duration(route.Penalty),
+			Algorithm: algorithm,
+			Burst:     route.Burst,
+		})
+	}
+	return routes
+}
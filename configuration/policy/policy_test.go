@@ -0,0 +1,194 @@
+package policy_test
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Higor-ViniciusDev/posgo_raterlimite/configuration/policy"
+	"github.com/Higor-ViniciusDev/posgo_raterlimite/internal/entity/limit_entity"
+)
+
+func writePolicy(t *testing.T, name string, content string) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatalf("erro ao gravar política: %v", err)
+	}
+	return path
+}
+
+func TestLoad_YAMLAndJSON(t *testing.T) {
+	files := map[string]string{
+		"policy.yaml": `
+strategies:
+  ip: { limit: 5, window: 1s, penalty: 2, ipv6_prefix: 64 }
+  tolken:
+    limit: 10
+    window: 1s
+    extra_windows: [{ limit: 1000, window: 1h }]
+    algorithm: token_bucket
+    penalty_steps: [1s, 1m]
+    costs: [{ method: post, path: /export, cost: 10 }]
+    plans:
+      pro: { limit: 100, window: 1s, penalty: 0 }
+routes:
+  - { method: POST, path: /login, limit: 5, window: 1m }
+chain: [tolken, ip]
+invalid_tolken:
+  - { method: get, path: /public, fallback: ip }
+`,
+		"policy.json": `{
+  "strategies": {
+    "ip": {"limit": 5, "window": "1s", "penalty": 2, "ipv6_prefix": 64},
+    "tolken": {
+      "limit": 10, "window": 1,
+      "extra_windows": [{"limit": 1000, "window": "1h"}],
+      "algorithm": "token_bucket",
+      "penalty_steps": ["1s", "1m"],
+      "costs": [{"method": "post", "path": "/export", "cost": 10}],
+      "plans": {"pro": {"limit": 100, "window": "1s"}}
+    }
+  },
+  "routes": [{"method": "POST", "path": "/login", "limit": 5, "window": "1m"}],
+  "chain": ["tolken", "ip"],
+  "invalid_tolken": [{"method": "get", "path": "/public", "fallback": "ip"}]
+}`,
+	}
+
+	for name, content := range files {
+		t.Run(name, func(t *testing.T) {
+			file, err := policy.Load(writePolicy(t, name, content))
+			if err != nil {
+				t.Fatalf("política válida recusada: %v", err)
+			}
+
+			ip := file.Strategies.IP.Limits()
+			if ip.Limit != 5 || ip.TTL != time.Second || ip.Penalty != 2*time.Second || ip.Algorithm != limit_entity.FixedWindow {
+				t.Fatalf("limites do ip inesperados: %+v", ip)
+			}
+			if ip.IPPrefix.Key("2001:db8::1") != "2001:db8::/64" {
+				t.Fatalf("prefixo do ip inesperado: %+v", ip.IPPrefix)
+			}
+
+			tolken := file.Strategies.Tolken.Limits()
+			if tolken.Algorithm != limit_entity.TokenBucket || len(tolken.ExtraWindows) != 1 || tolken.ExtraWindows[0].TTL != time.Hour {
+				t.Fatalf("limites do tolken inesperados: %+v", tolken)
+			}
+			if !tolken.PenaltyPolicy.Escalates() || tolken.PenaltyPolicy.Max() != time.Minute {
+				t.Fatalf("penalidade escalonada inesperada: %+v", tolken.PenaltyPolicy)
+			}
+			if tolken.CostRules.Cost("POST", "/export/csv") != 10 {
+				t.Fatalf("custo por rota inesperado: %+v", tolken.CostRules)
+			}
+
+			if pro, ok := tolken.Plans["pro"]; !ok || pro.Limit != 100 || pro.TTL != time.Second {
+				t.Fatalf("plano do tolken inesperado: %+v", tolken.Plans)
+			}
+
+			route, ok := file.RoutePolicies().Match("POST", "/login")
+			if !ok || route.Limit != 5 || route.TTL != time.Minute || route.Algorithm != "" {
+				t.Fatalf("rota inesperada: %+v", route)
+			}
+
+			if chain := file.PolicyChain(); len(chain) != 2 || !chain.Has(limit_entity.ChainIP) {
+				t.Fatalf("cadeia inesperada: %v", chain)
+			}
+
+			fallbacks := file.TolkenFallbackRules()
+			if fallbacks.Fallback("GET", "/public/docs") != limit_entity.FallbackIP || fallbacks.Fallback("GET", "/") != limit_entity.FallbackReject {
+				t.Fatalf("fallback do tolken inesperado: %+v", fallbacks)
+			}
+		})
+	}
+}
+
+func TestLoad_RejectsInvalidPolicy(t *testing.T) {
+	typo := writePolicy(t, "typo.yaml", `
+strategies:
+  ip: { limt: 5, window: 1s }
+  tolken: { limit: 10, window: 1s }
+`)
+	if _, err := policy.Load(typo); err == nil || !strings.Contains(err.Message, "limt") {
+		t.Fatalf("campo desconhecido deveria ser recusado, recebido %v", err)
+	}
+
+	invalid := writePolicy(t, "invalid.yaml", `
+strategies:
+  ip: { limit: 0, window: 1s, algorithm: leaky, ipv6_prefix: 129 }
+  tolken:
+    limit: 10
+    window: 1s
+    plans:
+      free: { limit: -1 }
+routes:
+  - { path: login, limit: 5, window: 1m }
+chain: [tolken, user]
+invalid_tolken:
+  - { path: /public, fallback: anonymous }
+`)
+	_, err := policy.Load(invalid)
+	if err == nil {
+		t.Fatal("política inválida deveria ser recusada")
+	}
+
+	// todos os problemas aparecem de uma vez
+	for _, problem := range []string{"strategies.ip.limit", "strategies.ip.algorithm", "strategies.ip.ipv6_prefix", "strategies.tolken.plans.free.limit", "routes[0].path", "chain", "invalid_tolken[0]"} {
+		if !strings.Contains(err.Message, problem) {
+			t.Fatalf("erro deveria citar %s, recebido %q", problem, err.Message)
+		}
+	}
+}
+
+func TestLoad_ExampleFile(t *testing.T) {
+	if _, err := policy.Load("../../cmd/ratelimite/policy.example.yaml"); err != nil {
+		t.Fatalf("arquivo de exemplo deveria ser válido: %v", err)
+	}
+}
+
+func TestFromEnv(t *testing.T) {
+	t.Setenv("REQUEST_PER_SECOND_IP", "5")
+	t.Setenv("TLL_KEY_IP", "1")
+	t.Setenv("TIME_UNLOCKED_NEW_REQUEST_IP", "3")
+	t.Setenv("REQUEST_PER_SECOND_TOLKEN", "10")
+	t.Setenv("TLL_KEY_TOLKEN", "1")
+	t.Setenv("EXTRA_WINDOWS_TOLKEN", "1000/1h")
+	t.Setenv("PLANS_TOLKEN", "free=10/1s/10s,pro=100/1s")
+	t.Setenv("ROUTE_POLICIES", "POST /login=5/1m")
+	t.Setenv("POLICY_CHAIN", "tolken,ip")
+	t.Setenv("INVALID_TOLKEN_FALLBACK", "/public=ip,/api=unauthorized")
+
+	file, err := policy.FromEnv()
+	if err != nil {
+		t.Fatalf("variáveis válidas recusadas: %v", err)
+	}
+	if ip := file.Strategies.IP.Limits(); ip.Limit != 5 || ip.Penalty != 3*time.Second {
+		t.Fatalf("limites do ip inesperados: %+v", ip)
+	}
+	if len(file.Strategies.Tolken.Limits().ExtraWindows) != 1 || len(file.RoutePolicies()) != 1 {
+		t.Fatalf("janelas ou rotas não foram lidas: %+v", file)
+	}
+	if free := file.Strategies.Tolken.Limits().Plans["free"]; free.Limit != 10 || free.Penalty != 10*time.Second {
+		t.Fatalf("planos não foram lidos: %+v", file.Strategies.Tolken.Plans)
+	}
+	if len(file.PolicyChain()) != 2 {
+		t.Fatalf("cadeia não foi lida: %v", file.Chain)
+	}
+	if file.TolkenFallbackRules().Fallback("POST", "/api/orders") != limit_entity.FallbackUnauthorized {
+		t.Fatalf("fallback do tolken não foi lido: %+v", file.InvalidTolken)
+	}
+
+	// antes um erro de digitação virava limite 0 e bloqueava tudo
+	t.Setenv("REQUEST_PER_SECOND_IP", "5O")
+	if _, err := policy.FromEnv(); err == nil || !strings.Contains(err.Message, "REQUEST_PER_SECOND_IP") {
+		t.Fatalf("número inválido deveria citar a variável, recebido %v", err)
+	}
+
+	t.Setenv("REQUEST_PER_SECOND_IP", "")
+	if _, err := policy.FromEnv(); err == nil || !strings.Contains(err.Message, "strategies.ip.limit") {
+		t.Fatalf("limite ausente deveria ser recusado, recebido %v", err)
+	}
+}
@@ -0,0 +1,121 @@
+package policy
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/Higor-ViniciusDev/posgo_raterlimite/configuration/logger"
+	"github.com/Higor-ViniciusDev/posgo_raterlimite/internal/internal_error"
+	"go.uber.org/zap"
+)
+
+// Intervalo padrão entre as verificações do arquivo
+const DefaultReloadInterval = 5 * time.Second
+
+// Watcher recarrega a política no SIGHUP e quando o arquivo observado muda.
+// A política nova só é aplicada depois de validada; com erro a atual continua valendo.
+type Watcher struct {
+	path     string
+	interval time.Duration
+	load     func() (*File, *internal_error.InternalError)
+	apply    func(*File)
+
+	mu      sync.Mutex
+	current *File
+	modTime time.Time
+	size    int64
+}
+
+// NewWatcher observa o arquivo em path (a cada interval, 0 desliga a verificação e fica só o SIGHUP).
+// load lê a política de novo e apply recebe a política nova já validada.
+func NewWatcher(path string, current *File, interval time.Duration, load func() (*File, *internal_error.InternalError), apply func(*File)) *Watcher {
+	w := &Watcher{
+		path:     path,
+		interval: interval,
+		load:     load,
+		apply:    apply,
+		current:  current,
+	}
+	w.modTime, w.size = w.stat()
+	return w
+}
+
+// Start observa o SIGHUP e o arquivo até o contexto ser cancelado
+func (w *Watcher) Start(ctx context.Context) {
+	hangup := make(chan os.Signal, 1)
+	signal.Notify(hangup, syscall.SIGHUP)
+
+	var tick <-chan time.Time
+	if w.interval > 0 {
+		ticker := time.NewTicker(w.interval)
+		tick = ticker.C
+		defer ticker.Stop()
+	}
+
+	defer signal.Stop(hangup)
+
+	for {
+		select {
+		case <-hangup:
+			logger.Info("SIGHUP recebido, recarregando politica", zap.String("path", w.path))
+			w.Reload()
+		case <-tick:
+			if w.changed() {
+				logger.Info("arquivo de politica alterado, recarregando", zap.String("path", w.path))
+				w.Reload()
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// Reload lê e valida a política e aplica quando algo mudou, logando os campos alterados
+func (w *Watcher) Reload() *internal_error.InternalError {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	next, err := w.load()
+	if err != nil {
+		logger.Error("politica recarregada invalida, mantendo a atual", err)
+		return err
+	}
+
+	changes := Diff(w.current, next)
+	if len(changes) == 0 {
+		logger.Info("politica recarregada sem alteracoes")
+		return nil
+	}
+
+	w.apply(next)
+	w.current = next
+	logger.Info("politica recarregada", zap.String("diff", strings.Join(changes, "; ")))
+	return nil
+}
+
+// changed compara a data de modificação e o tamanho do arquivo com a última verificação
+func (w *Watcher) changed() bool {
+	modTime, size := w.stat()
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if modTime.Equal(w.modTime) && size == w.size {
+		return false
+	}
+	w.modTime, w.size = modTime, size
+	return true
+}
+
+func (w *Watcher) stat() (time.Time, int64) {
+	info, err := os.Stat(w.path)
+	if err != nil {
+		return time.Time{}, 0
+	}
+	return info.ModTime(), info.Size()
+}
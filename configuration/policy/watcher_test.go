@@ -0,0 +1,92 @@
+package policy_test
+
+import (
+	"context"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Higor-ViniciusDev/posgo_raterlimite/configuration/policy"
+	"github.com/Higor-ViniciusDev/posgo_raterlimite/internal/internal_error"
+)
+
+const watchedPolicy = `
+strategies:
+  ip: { limit: %LIMIT%, window: 1s }
+  tolken: { limit: 10, window: 1s }
+`
+
+func TestWatcher_ReloadsChangedFile(t *testing.T) {
+	path := writePolicy(t, "policy.yaml", strings.ReplaceAll(watchedPolicy, "%LIMIT%", "5"))
+
+	current, err := policy.Load(path)
+	if err != nil {
+		t.Fatalf("política inicial recusada: %v", err)
+	}
+
+	applied := make(chan *policy.File, 1)
+	watcher := policy.NewWatcher(path, current, 20*time.Millisecond, func() (*policy.File, *internal_error.InternalError) {
+		return policy.Load(path)
+	}, func(file *policy.File) {
+		applied <- file
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go watcher.Start(ctx)
+
+	// valor inválido mantém a política atual
+	if err := os.WriteFile(path, []byte(strings.ReplaceAll(watchedPolicy, "%LIMIT%", "-10")), 0o600); err != nil {
+		t.Fatalf("erro ao gravar política: %v", err)
+	}
+	select {
+	case file := <-applied:
+		t.Fatalf("política inválida não deveria ser aplicada: %+v", file)
+	case <-time.After(200 * time.Millisecond):
+	}
+
+	if err := os.WriteFile(path, []byte(strings.ReplaceAll(watchedPolicy, "%LIMIT%", "50")), 0o600); err != nil {
+		t.Fatalf("erro ao gravar política: %v", err)
+	}
+	select {
+	case file := <-applied:
+		if file.Strategies.IP.Limit != 50 {
+			t.Fatalf("esperado o limite novo 50, recebido %d", file.Strategies.IP.Limit)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("a alteração do arquivo deveria recarregar a política")
+	}
+
+	// recarregar sem mudanças não aplica de novo
+	if err := watcher.Reload(); err != nil {
+		t.Fatalf("reload sem mudanças não deveria falhar: %v", err)
+	}
+	select {
+	case file := <-applied:
+		t.Fatalf("política igual não deveria ser aplicada de novo: %+v", file)
+	default:
+	}
+}
+
+func TestDiff(t *testing.T) {
+	previous := &policy.File{Strategies: policy.Strategies{IP: policy.Strategy{Limit: 5, Window: policy.Duration(time.Second)}}}
+	next := &policy.File{
+		Strategies: policy.Strategies{IP: policy.Strategy{Limit: 10, Window: policy.Duration(time.Second)}},
+		Routes:     []policy.Route{{Path: "/login", Limit: 5, Window: policy.Duration(time.Minute)}},
+	}
+
+	changes := strings.Join(policy.Diff(previous, next), "\n")
+	for _, expected := range []string{"strategies.ip.limit: 5 -> 10", `routes[0].path: - -> "/login"`, `routes[0].window: - -> "1m0s"`} {
+		if !strings.Contains(changes, expected) {
+			t.Fatalf("diff deveria conter %q, recebido:\n%s", expected, changes)
+		}
+	}
+	if strings.Contains(changes, "strategies.ip.window") {
+		t.Fatalf("campo sem alteração não deveria aparecer no diff:\n%s", changes)
+	}
+
+	if changes := policy.Diff(next, next); len(changes) != 0 {
+		t.Fatalf("políticas iguais não deveriam ter diff: %v", changes)
+	}
+}
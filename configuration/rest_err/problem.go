@@ -0,0 +1,34 @@
+package rest_err
+
+import "net/http"
+
+// ProblemContentType é o media type do RFC 7807
+const ProblemContentType = "application/problem+json"
+
+// Prefixo do type dos problemas, seguido do Err do RestErr (ex: urn:problem-type:many_request)
+const problemTypePrefix = "urn:problem-type:"
+
+// ProblemDetails é o corpo de erro no formato do RFC 7807, com as extensões do rate limiter
+type ProblemDetails struct {
+	Type     string `json:"type"`
+	Title    string `json:"title"`
+	Status   int    `json:"status"`
+	Detail   string `json:"detail"`
+	Instance string `json:"instance,omitempty"`
+
+	// Strategy que bloqueou a requisição (IP ou TOLKEN)
+	Strategy string `json:"strategy,omitempty"`
+	// RetryAfter é quantos segundos o cliente precisa esperar
+	RetryAfter int64 `json:"retry_after,omitempty"`
+}
+
+// NewProblemDetails converte o RestErr para o formato problem+json
+func NewProblemDetails(restErr *RestErr, instance string) *ProblemDetails {
+	return &ProblemDetails{
+		Type:     problemTypePrefix + restErr.Err,
+		Title:    http.StatusText(restErr.Code),
+		Status:   restErr.Code,
+		Detail:   restErr.Message,
+		Instance: instance,
+	}
+}
@@ -30,6 +30,12 @@ func ConvertInternalErrorToRestError(err *internal_error.InternalError) *RestErr
 		return NewNotFoundError(err.Error())
 	case "many_request":
 		return NewManyRequestError(err.Error())
+	case "request_timeout":
+		return NewRequestTimeoutError(err.Error())
+	case "forbidden":
+		return NewForbiddenError(err.Error())
+	case "unauthorized":
+		return NewUnauthorizedError(err.Error())
 	default:
 		return NewInternalServerError(err.Error())
 	}
@@ -70,3 +76,48 @@ func NewManyRequestError(message string) *RestErr {
 		Causes:  nil,
 	}
 }
+
+func NewForbiddenError(message string) *RestErr {
+	return &RestErr{
+		Message: message,
+		Err:     "forbidden",
+		Code:    http.StatusForbidden,
+		Causes:  nil,
+	}
+}
+
+func NewUnauthorizedError(message string) *RestErr {
+	return &RestErr{
+		Message: message,
+		Err:     "unauthorized",
+		Code:    http.StatusUnauthorized,
+		Causes:  nil,
+	}
+}
+
+func NewRequestTimeoutError(message string) *RestErr {
+	return &RestErr{
+		Message: message,
+		Err:     "request_timeout",
+		Code:    http.StatusRequestTimeout,
+		Causes:  nil,
+	}
+}
+
+func NewBadGatewayError(message string) *RestErr {
+	return &RestErr{
+		Message: message,
+		Err:     "bad_gateway",
+		Code:    http.StatusBadGateway,
+		Causes:  nil,
+	}
+}
+
+func NewGatewayTimeoutError(message string) *RestErr {
+	return &RestErr{
+		Message: message,
+		Err:     "gateway_timeout",
+		Code:    http.StatusGatewayTimeout,
+		Causes:  nil,
+	}
+}
@@ -0,0 +1,107 @@
+package access_list_entity
+
+import (
+	"context"
+	"net/netip"
+	"strings"
+
+	"github.com/Higor-ViniciusDev/posgo_raterlimite/internal/internal_error"
+)
+
+// List é uma das listas de acesso: allow passa direto pelo rate limiter e deny recebe 403
+type List string
+
+const (
+	Allow List = "allow"
+	Deny  List = "deny"
+)
+
+// ParseList aceita "allow" ou "deny", sem diferenciar maiúsculas
+func ParseList(value string) (List, *internal_error.InternalError) {
+	switch List(strings.ToLower(strings.TrimSpace(value))) {
+	case Allow:
+		return Allow, nil
+	case Deny:
+		return Deny, nil
+	default:
+		return "", internal_error.NewBadRequestError("access list invalid: " + value)
+	}
+}
+
+// ParseEntry converte um IP ou CIDR (IPv4 ou IPv6) em prefixo. IP sozinho vira /32 ou /128
+// e o CIDR é normalizado para o endereço da rede (ex: 10.0.0.7/8 vira 10.0.0.0/8).
+// IPv4 mapeado em IPv6 vira IPv4, como o IP consultado (ex: ::ffff:10.0.0.0/104 vira 10.0.0.0/8).
+func ParseEntry(value string) (netip.Prefix, *internal_error.InternalError) {
+	value = strings.TrimSpace(value)
+
+	if strings.Contains(value, "/") {
+		prefix, err := netip.ParsePrefix(value)
+		if err != nil {
+			return netip.Prefix{}, internal_error.NewBadRequestError("access list entry invalid: " + value)
+		}
+		prefix = prefix.Masked()
+		if prefix.Addr().Is4In6() {
+			prefix = netip.PrefixFrom(prefix.Addr().Unmap(), prefix.Bits()-96)
+		}
+		return prefix, nil
+	}
+
+	addr, err := netip.ParseAddr(value)
+	if err != nil {
+		return netip.Prefix{}, internal_error.NewBadRequestError("access list entry invalid: " + value)
+	}
+	addr = addr.Unmap()
+	return netip.PrefixFrom(addr, addr.BitLen()), nil
+}
+
+// Action é o que a lista de acesso decidiu para o IP
+type Action int
+
+const (
+	// None segue para o rate limiter normalmente
+	None Action = iota
+	// Allowed passa sem rate limit
+	Allowed
+	// Denied é recusado com 403
+	Denied
+)
+
+// AccessList são as faixas de cada lista
+type AccessList struct {
+	Allow []netip.Prefix
+	Deny  []netip.Prefix
+}
+
+// Check procura o IP nas listas. Deny vale sobre allow, assim uma faixa liberada pode ter IPs bloqueados.
+// IP inválido ou vazio segue para o rate limiter.
+func (a AccessList) Check(ip string) Action {
+	addr, err := netip.ParseAddr(strings.TrimSpace(ip))
+	if err != nil {
+		return None
+	}
+	addr = addr.Unmap()
+
+	if contains(a.Deny, addr) {
+		return Denied
+	}
+	if contains(a.Allow, addr) {
+		return Allowed
+	}
+	return None
+}
+
+func contains(prefixes []netip.Prefix, addr netip.Addr) bool {
+	for _, prefix := range prefixes {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+type AccessListRepositoryInterface interface {
+	Add(ctx context.Context, list List, entry netip.Prefix) *internal_error.InternalError
+	Remove(ctx context.Context, list List, entry netip.Prefix) *internal_error.InternalError
+	// FindAll devolve as duas listas, entradas inválidas gravadas por fora são ignoradas
+	FindAll(ctx context.Context) (*AccessList, *internal_error.InternalError)
+}
@@ -0,0 +1,69 @@
+package access_list_entity_test
+
+import (
+	"net/netip"
+	"testing"
+
+	"github.com/Higor-ViniciusDev/posgo_raterlimite/internal/entity/access_list_entity"
+)
+
+func TestParseEntry(t *testing.T) {
+	cases := map[string]string{
+		"203.0.113.7":          "203.0.113.7/32",
+		"10.0.0.7/8":           "10.0.0.0/8",
+		"2001:db8::1":          "2001:db8::1/128",
+		"2001:db8::/32":        "2001:db8::/32",
+		"::ffff:192.0.2.1":     "192.0.2.1/32",
+		"::ffff:10.0.0.0/104":  "10.0.0.0/8",
+		"::ffff:192.0.2.9/120": "192.0.2.0/24",
+	}
+	for value, expected := range cases {
+		prefix, err := access_list_entity.ParseEntry(value)
+		if err != nil || prefix.String() != expected {
+			t.Fatalf("%s: esperado %s, recebido %s (%v)", value, expected, prefix, err)
+		}
+	}
+
+	for _, value := range []string{"", "not-an-ip", "10.0.0.0/33"} {
+		if _, err := access_list_entity.ParseEntry(value); err == nil {
+			t.Fatalf("%q deveria ser recusado", value)
+		}
+	}
+}
+
+func TestAccessList_CheckMappedCIDR(t *testing.T) {
+	prefix, _ := access_list_entity.ParseEntry("::ffff:10.0.0.0/104")
+	list := access_list_entity.AccessList{Deny: []netip.Prefix{prefix}}
+
+	for _, ip := range []string{"10.1.2.3", "::ffff:10.1.2.3"} {
+		if got := list.Check(ip); got != access_list_entity.Denied {
+			t.Fatalf("%q deveria cair no CIDR mapeado, recebido %d", ip, got)
+		}
+	}
+	if got := list.Check("11.0.0.1"); got != access_list_entity.None {
+		t.Fatalf("IP fora do CIDR mapeado deveria seguir, recebido %d", got)
+	}
+}
+
+func TestAccessList_CheckDenyWinsOverAllow(t *testing.T) {
+	var list access_list_entity.AccessList
+	for _, entry := range []string{"10.0.0.0/8", "2001:db8::/32"} {
+		prefix, _ := access_list_entity.ParseEntry(entry)
+		list.Allow = append(list.Allow, prefix)
+	}
+	denied, _ := access_list_entity.ParseEntry("10.0.0.66")
+	list.Deny = append(list.Deny, denied)
+
+	cases := map[string]access_list_entity.Action{
+		"10.1.2.3":    access_list_entity.Allowed,
+		"10.0.0.66":   access_list_entity.Denied,
+		"2001:db8::9": access_list_entity.Allowed,
+		"192.0.2.1":   access_list_entity.None,
+		"":            access_list_entity.None,
+	}
+	for ip, expected := range cases {
+		if got := list.Check(ip); got != expected {
+			t.Fatalf("%q: esperado %d, recebido %d", ip, expected, got)
+		}
+	}
+}
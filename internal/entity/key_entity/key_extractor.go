@@ -0,0 +1,257 @@
+package key_entity
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/Higor-ViniciusDev/posgo_raterlimite/internal/internal_error"
+	"github.com/go-chi/jwtauth"
+)
+
+// Source é de onde os extractors leem os dados do cliente em cada transporte
+// (headers, query e cookies no HTTP, metadata no gRPC).
+type Source interface {
+	// Header devolve o primeiro valor do header/metadata, vazio quando não existir
+	Header(name string) string
+	// Headers são todos os headers/metadata, com os nomes no formato do HTTP
+	Headers() http.Header
+	// Query devolve o parâmetro da URL, vazio no gRPC
+	Query(name string) string
+	// Cookie devolve o valor do cookie, vazio quando não existir
+	Cookie(name string) string
+	// RemoteAddr é o endereço da conexão (host:porta)
+	RemoteAddr() string
+	// ClientIP é o IP do cliente já resolvido pela estratégia de proxies confiáveis
+	ClientIP() string
+}
+
+// KeyExtractor tira da requisição a identificação do cliente usada na key do rate limit
+type KeyExtractor interface {
+	// Extract devolve a identificação, vazio quando a requisição não tiver
+	Extract(src Source) string
+	// String é a configuração do extractor (ex: "header:API-KEY")
+	String() string
+}
+
+// Authenticator é implementado pelos extractors que já validam a identificação (ex: claim de um JWT assinado),
+// assim a strategy não precisa conferir o tolken no Redis
+type Authenticator interface {
+	Authenticates() bool
+}
+
+// Authenticates informa se o extractor já valida a identificação
+func Authenticates(extractor KeyExtractor) bool {
+	authenticator, ok := extractor.(Authenticator)
+	return ok && authenticator.Authenticates()
+}
+
+// Extractors padrão de cada strategy, iguais ao comportamento antes da configuração
+var (
+	DefaultTolkenExtractor KeyExtractor = HeaderExtractor{Name: "API-KEY"}
+	DefaultIPExtractor     KeyExtractor = ClientIPExtractor{}
+)
+
+// ParseKeyExtractor converte a configuração do extractor. Vários separados por vírgula são tentados em ordem
+// até um encontrar a identificação (ex: "bearer,query:api_key"). Vazio devolve nil, a strategy usa o padrão.
+//
+//	ip              IP do cliente
+//	header:NOME     valor do header
+//	bearer          tolken do Authorization: Bearer
+//	query:NOME      parâmetro da URL
+//	cookie:NOME     valor do cookie
+//	jwt:CLAIM       claim do JWT do Authorization: Bearer, assinado com JWT_SECRET (obrigatório)
+func ParseKeyExtractor(value string) (KeyExtractor, *internal_error.InternalError) {
+	var chain ChainExtractor
+
+	for _, part := range strings.Split(value, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+
+		kind, name, _ := strings.Cut(part, ":")
+		kind = strings.ToLower(strings.TrimSpace(kind))
+		name = strings.TrimSpace(name)
+
+		needsName := kind == "header" || kind == "query" || kind == "cookie" || kind == "jwt"
+		if needsName && name == "" {
+			return nil, internal_error.NewBadRequestError("key extractor needs a name: " + part)
+		}
+		if !needsName && name != "" {
+			return nil, internal_error.NewBadRequestError("key extractor does not take a name: " + part)
+		}
+
+		switch kind {
+		case "ip":
+			chain = append(chain, ClientIPExtractor{})
+		case "header":
+			chain = append(chain, HeaderExtractor{Name: name})
+		case "bearer":
+			chain = append(chain, BearerExtractor{})
+		case "query":
+			chain = append(chain, QueryExtractor{Name: name})
+		case "cookie":
+			chain = append(chain, CookieExtractor{Name: name})
+		case "jwt":
+			// sem segredo não há como validar a assinatura e o cliente escolheria a própria key
+			secret := os.Getenv("JWT_SECRET")
+			if secret == "" {
+				return nil, internal_error.NewBadRequestError("key extractor needs JWT_SECRET: " + part)
+			}
+			chain = append(chain, NewJWTClaimExtractor(name, secret))
+		default:
+			return nil, internal_error.NewBadRequestError("key extractor invalid: " + part)
+		}
+	}
+
+	switch len(chain) {
+	case 0:
+		return nil, nil
+	case 1:
+		return chain[0], nil
+	default:
+		return chain, nil
+	}
+}
+
+// ClientIPExtractor usa o IP do cliente
+type ClientIPExtractor struct{}
+
+func (ClientIPExtractor) Extract(src Source) string {
+	return src.ClientIP()
+}
+
+func (ClientIPExtractor) String() string {
+	return "ip"
+}
+
+// HeaderExtractor usa o valor de um header (API-KEY, X-Tenant...)
+type HeaderExtractor struct {
+	Name string
+}
+
+func (e HeaderExtractor) Extract(src Source) string {
+	return strings.TrimSpace(src.Header(e.Name))
+}
+
+func (e HeaderExtractor) String() string {
+	return "header:" + e.Name
+}
+
+// BearerExtractor usa o tolken do Authorization: Bearer
+type BearerExtractor struct{}
+
+func (BearerExtractor) Extract(src Source) string {
+	return bearerToken(src)
+}
+
+func (BearerExtractor) String() string {
+	return "bearer"
+}
+
+// QueryExtractor usa um parâmetro da URL (?api_key=)
+type QueryExtractor struct {
+	Name string
+}
+
+func (e QueryExtractor) Extract(src Source) string {
+	return strings.TrimSpace(src.Query(e.Name))
+}
+
+func (e QueryExtractor) String() string {
+	return "query:" + e.Name
+}
+
+// CookieExtractor usa o valor de um cookie (sessão)
+type CookieExtractor struct {
+	Name string
+}
+
+func (e CookieExtractor) Extract(src Source) string {
+	return strings.TrimSpace(src.Cookie(e.Name))
+}
+
+func (e CookieExtractor) String() string {
+	return "cookie:" + e.Name
+}
+
+// JWTClaimExtractor usa uma claim (tenant_id, sub...) do JWT do Authorization: Bearer.
+// Só vale JWT com assinatura e validade corretas, senão qualquer um trocaria a claim para fugir do limite.
+type JWTClaimExtractor struct {
+	Claim string
+	auth  *jwtauth.JWTAuth
+}
+
+func NewJWTClaimExtractor(claim string, secret string) JWTClaimExtractor {
+	return JWTClaimExtractor{
+		Claim: claim,
+		auth:  jwtauth.New("HS256", []byte(secret), nil),
+	}
+}
+
+func (e JWTClaimExtractor) Extract(src Source) string {
+	tolken := bearerToken(src)
+	if tolken == "" || e.auth == nil {
+		return ""
+	}
+
+	token, err := jwtauth.VerifyToken(e.auth, tolken)
+	if err != nil {
+		return ""
+	}
+
+	value, ok := token.Get(e.Claim)
+	if !ok || value == nil {
+		return ""
+	}
+	return strings.TrimSpace(fmt.Sprint(value))
+}
+
+func (e JWTClaimExtractor) String() string {
+	return "jwt:" + e.Claim
+}
+
+// Authenticates: a claim só sai de um JWT com assinatura válida
+func (e JWTClaimExtractor) Authenticates() bool {
+	return true
+}
+
+// ChainExtractor tenta os extractors em ordem até um encontrar a identificação
+type ChainExtractor []KeyExtractor
+
+func (c ChainExtractor) Extract(src Source) string {
+	for _, extractor := range c {
+		if key := extractor.Extract(src); key != "" {
+			return key
+		}
+	}
+	return ""
+}
+
+func (c ChainExtractor) String() string {
+	names := make([]string, 0, len(c))
+	for _, extractor := range c {
+		names = append(names, extractor.String())
+	}
+	return strings.Join(names, ",")
+}
+
+func bearerToken(src Source) string {
+	scheme, tolken, found := strings.Cut(strings.TrimSpace(src.Header("Authorization")), " ")
+	if !found || !strings.EqualFold(scheme, "Bearer") {
+		return ""
+	}
+	return strings.TrimSpace(tolken)
+}
+
+// Authenticates vale quando todos os extractors da cadeia já validam a identificação
+func (c ChainExtractor) Authenticates() bool {
+	for _, extractor := range c {
+		if !Authenticates(extractor) {
+			return false
+		}
+	}
+	return len(c) > 0
+}
@@ -0,0 +1,74 @@
+package key_entity_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/Higor-ViniciusDev/posgo_raterlimite/internal/entity/key_entity"
+	"github.com/Higor-ViniciusDev/posgo_raterlimite/internal/keyresolver"
+	"github.com/go-chi/jwtauth"
+)
+
+func TestParseKeyExtractor_Extract(t *testing.T) {
+	t.Setenv("JWT_SECRET", "secret")
+
+	_, signed, _ := jwtauth.New("HS256", []byte("secret"), nil).Encode(map[string]interface{}{"tenant_id": "acme"})
+	_, forged, _ := jwtauth.New("HS256", []byte("other"), nil).Encode(map[string]interface{}{"tenant_id": "acme"})
+
+	req := httptest.NewRequest(http.MethodGet, "/orders?api_key=q-123", nil)
+	req.RemoteAddr = "10.0.0.1:1234"
+	req.Header.Set("API-KEY", "h-123")
+	req.Header.Set("Authorization", "Bearer "+signed)
+	req.AddCookie(&http.Cookie{Name: "session", Value: "s-123"})
+	src := keyresolver.FromHTTP(req)
+
+	cases := map[string]string{
+		"header:API-KEY":            "h-123",
+		"query:api_key":             "q-123",
+		"cookie:session":            "s-123",
+		"bearer":                    signed,
+		"jwt:tenant_id":             "acme",
+		"jwt:missing":               "",
+		"ip":                        "10.0.0.1",
+		"header:X-Tenant, ip":       "10.0.0.1",
+		"cookie:none,query:api_key": "q-123",
+	}
+	for spec, expected := range cases {
+		extractor, err := key_entity.ParseKeyExtractor(spec)
+		if err != nil {
+			t.Fatalf("%s: configuração válida recusada: %v", spec, err)
+		}
+		if got := extractor.Extract(src); got != expected {
+			t.Fatalf("%s: esperado %q, recebido %q", spec, expected, got)
+		}
+	}
+
+	// JWT assinado com outra chave não vale, senão o cliente escolheria o tenant
+	req.Header.Set("Authorization", "Bearer "+forged)
+	extractor, _ := key_entity.ParseKeyExtractor("jwt:tenant_id")
+	if got := extractor.Extract(src); got != "" {
+		t.Fatalf("JWT com assinatura inválida deveria ser ignorado, recebido %q", got)
+	}
+	if !key_entity.Authenticates(extractor) {
+		t.Fatal("claim de JWT deveria dispensar a validação do tolken")
+	}
+}
+
+func TestParseKeyExtractor_RejectsInvalid(t *testing.T) {
+	if extractor, err := key_entity.ParseKeyExtractor(""); err != nil || extractor != nil {
+		t.Fatalf("vazio deveria usar o padrão da strategy, recebido %v %v", extractor, err)
+	}
+
+	for _, spec := range []string{"header", "query:", "bearer:x", "form:api_key"} {
+		if _, err := key_entity.ParseKeyExtractor(spec); err == nil {
+			t.Fatalf("%q deveria ser recusado", spec)
+		}
+	}
+
+	// sem JWT_SECRET a assinatura não seria validada
+	t.Setenv("JWT_SECRET", "")
+	if _, err := key_entity.ParseKeyExtractor("bearer,jwt:tenant_id"); err == nil || err.Err != "bad_request" {
+		t.Fatalf("jwt sem JWT_SECRET deveria ser bad request, recebido %v", err)
+	}
+}
@@ -0,0 +1,48 @@
+package limit_entity
+
+import (
+	"strings"
+
+	"github.com/Higor-ViniciusDev/posgo_raterlimite/internal/internal_error"
+)
+
+// Strategies que podem entrar na cadeia da política
+const (
+	ChainIP     = "ip"
+	ChainTolken = "tolken"
+)
+
+// Chain são as strategies verificadas juntas em cada requisição (ex: tolken e ip), na ordem da lista.
+// A requisição passa só se couber em todas. Vazia decide por uma só: o tolken quando presente, senão o ip.
+type Chain []string
+
+// ParseChain lê a cadeia separada por vírgula (ex: "tolken,ip"), sem strategies repetidas
+func ParseChain(value string) (Chain, *internal_error.InternalError) {
+	var chain Chain
+
+	for _, part := range strings.Split(value, ",") {
+		name := strings.ToLower(strings.TrimSpace(part))
+		if name == "" {
+			continue
+		}
+		if name != ChainIP && name != ChainTolken {
+			return nil, internal_error.NewBadRequestError("chain strategy unknown: " + part)
+		}
+		if chain.Has(name) {
+			return nil, internal_error.NewBadRequestError("chain strategy repeated: " + name)
+		}
+		chain = append(chain, name)
+	}
+
+	return chain, nil
+}
+
+// Has indica se a strategy faz parte da cadeia
+func (c Chain) Has(name string) bool {
+	for _, item := range c {
+		if item == name {
+			return true
+		}
+	}
+	return false
+}
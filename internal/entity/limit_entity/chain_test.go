@@ -0,0 +1,27 @@
+package limit_entity_test
+
+import (
+	"testing"
+
+	"github.com/Higor-ViniciusDev/posgo_raterlimite/internal/entity/limit_entity"
+)
+
+func TestParseChain(t *testing.T) {
+	chain, err := limit_entity.ParseChain(" Tolken, ip ")
+	if err != nil {
+		t.Fatalf("erro ao converter a cadeia: %v", err)
+	}
+	if len(chain) != 2 || chain[0] != limit_entity.ChainTolken || chain[1] != limit_entity.ChainIP {
+		t.Fatalf("cadeia inesperada: %v", chain)
+	}
+
+	if chain, err := limit_entity.ParseChain(""); err != nil || len(chain) != 0 {
+		t.Fatalf("cadeia vazia deveria ser aceita sem strategies, recebido %v (%v)", chain, err)
+	}
+
+	for _, value := range []string{"tolken,user", "ip,ip"} {
+		if _, err := limit_entity.ParseChain(value); err == nil {
+			t.Fatalf("cadeia %q deveria retornar erro", value)
+		}
+	}
+}
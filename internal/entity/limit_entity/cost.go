@@ -0,0 +1,49 @@
+package limit_entity
+
+import (
+	"strconv"
+
+	"github.com/Higor-ViniciusDev/posgo_raterlimite/internal/internal_error"
+)
+
+// CostRule define quanto uma rota consome da cota. Method vazio vale para qualquer método e
+// Path casa com o próprio caminho e com os abaixo dele (/export casa /export/csv, não /exporter).
+type CostRule struct {
+	Method string
+	Path   string
+	Cost   int64
+}
+
+// CostRules são avaliadas na ordem, a primeira que casar define o custo.
+type CostRules []CostRule
+
+// ParseCostRules converte a configuração "METODO /caminho=custo" separada por vírgula
+// (ex: "POST /export=10,/reports=5"). Vazio deixa todas as requisições com custo 1.
+func ParseCostRules(value string) (CostRules, *internal_error.InternalError) {
+	var rules CostRules
+
+	err := parsePathRules(value, "cost rule", func(method string, path string, costStr string, part string) *internal_error.InternalError {
+		cost, err := strconv.ParseInt(costStr, 10, 64)
+		if err != nil || cost <= 0 {
+			return internal_error.NewBadRequestError("cost rule invalid: " + part)
+		}
+
+		rules = append(rules, CostRule{Method: method, Path: path, Cost: cost})
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return rules, nil
+}
+
+// Cost devolve o custo da requisição, 1 quando nenhuma regra casar
+func (c CostRules) Cost(method string, path string) int64 {
+	for _, rule := range c {
+		if matchRoute(rule.Method, rule.Path, method, path) {
+			return rule.Cost
+		}
+	}
+	return 1
+}
@@ -0,0 +1,36 @@
+package limit_entity_test
+
+import (
+	"testing"
+
+	"github.com/Higor-ViniciusDev/posgo_raterlimite/internal/entity/limit_entity"
+)
+
+func TestCostRules_FirstMatchWins(t *testing.T) {
+	rules, err := limit_entity.ParseCostRules("POST /export=10, /reports=5")
+	if err != nil {
+		t.Fatalf("erro ao converter regras de custo: %v", err)
+	}
+
+	cases := []struct {
+		method, path string
+		cost         int64
+	}{
+		{"POST", "/export/csv", 10},
+		{"GET", "/export", 1},
+		{"POST", "/exporter", 1},
+		{"GET", "/reportsx", 1},
+		{"GET", "/reports/daily", 5},
+		{"GET", "/", 1},
+	}
+
+	for _, c := range cases {
+		if got := rules.Cost(c.method, c.path); got != c.cost {
+			t.Fatalf("%s %s: esperado custo %d, recebido %d", c.method, c.path, c.cost, got)
+		}
+	}
+
+	if _, err := limit_entity.ParseCostRules("POST /export"); err == nil {
+		t.Fatal("regra sem custo deveria retornar erro")
+	}
+}
@@ -0,0 +1,37 @@
+package limit_entity
+
+import (
+	"net/netip"
+	"strings"
+)
+
+// IPPrefix agrupa os IPs do mesmo prefixo em um contador só (ex: /64 no IPv6, onde o cliente controla
+// a rede inteira e pode trocar de endereço a cada requisição). Zero usa o endereço completo.
+type IPPrefix struct {
+	IPv4 int
+	IPv6 int
+}
+
+// Key normaliza o IP (IPv4 mapeado em IPv6 vira IPv4) e devolve o endereço ou o prefixo agregado
+// (ex: "2001:db8:1:2::/64"). Valor que não é IP volta sem alteração.
+func (p IPPrefix) Key(ip string) string {
+	addr, err := netip.ParseAddr(strings.TrimSpace(ip))
+	if err != nil {
+		return ip
+	}
+	addr = addr.Unmap().WithZone("")
+
+	bits := p.IPv6
+	if addr.Is4() {
+		bits = p.IPv4
+	}
+	if bits <= 0 || bits >= addr.BitLen() {
+		return addr.String()
+	}
+
+	prefix, err := addr.Prefix(bits)
+	if err != nil {
+		return addr.String()
+	}
+	return prefix.String()
+}
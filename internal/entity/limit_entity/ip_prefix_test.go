@@ -0,0 +1,32 @@
+package limit_entity_test
+
+import (
+	"testing"
+
+	"github.com/Higor-ViniciusDev/posgo_raterlimite/internal/entity/limit_entity"
+)
+
+func TestIPPrefix_Key(t *testing.T) {
+	aggregated := limit_entity.IPPrefix{IPv4: 24, IPv6: 64}
+	cases := []struct {
+		prefix limit_entity.IPPrefix
+		ip     string
+		key    string
+	}{
+		{aggregated, "2001:db8:1:2:aaaa::1", "2001:db8:1:2::/64"},
+		{aggregated, "2001:db8:1:2:bbbb::9", "2001:db8:1:2::/64"},
+		{aggregated, "203.0.113.7", "203.0.113.0/24"},
+		// IPv4 mapeado divide o contador com o IPv4
+		{aggregated, "::ffff:203.0.113.9", "203.0.113.0/24"},
+		{limit_entity.IPPrefix{}, "::ffff:203.0.113.9", "203.0.113.9"},
+		{limit_entity.IPPrefix{}, "2001:DB8::1", "2001:db8::1"},
+		{limit_entity.IPPrefix{IPv6: 128}, "2001:db8::1", "2001:db8::1"},
+		{aggregated, "not-an-ip", "not-an-ip"},
+	}
+
+	for _, c := range cases {
+		if got := c.prefix.Key(c.ip); got != c.key {
+			t.Fatalf("%+v %s: esperado %s, recebido %s", c.prefix, c.ip, c.key, got)
+		}
+	}
+}
@@ -0,0 +1,41 @@
+package limit_entity
+
+import (
+	"strings"
+
+	"github.com/Higor-ViniciusDev/posgo_raterlimite/internal/internal_error"
+)
+
+// Algorithm define qual algoritmo o rate limiter usa para decidir uma requisição.
+type Algorithm string
+
+const (
+	// FixedWindow conta as requisições e zera o contador quando a janela (TTL) expira.
+	FixedWindow Algorithm = "fixed_window"
+	// TokenBucket permite rajadas até a capacidade do bucket, reabastecendo a uma taxa constante.
+	TokenBucket Algorithm = "token_bucket"
+	// GCRA (Generic Cell Rate Algorithm) guarda apenas o tempo teórico de chegada (TAT) por key.
+	GCRA Algorithm = "gcra"
+	// SlidingLog guarda o horário de cada requisição e conta apenas as que estão dentro do último TTL.
+	SlidingLog Algorithm = "sliding_log"
+	// SlidingWindowCounter pondera o contador da janela anterior pela fração que ainda cobre o último TTL.
+	SlidingWindowCounter Algorithm = "sliding_window_counter"
+)
+
+// ParseAlgorithm converte o valor de configuração em Algorithm. Vazio assume FixedWindow.
+func ParseAlgorithm(value string) (Algorithm, *internal_error.InternalError) {
+	switch Algorithm(strings.ToLower(strings.TrimSpace(value))) {
+	case "", FixedWindow:
+		return FixedWindow, nil
+	case TokenBucket:
+		return TokenBucket, nil
+	case GCRA:
+		return GCRA, nil
+	case SlidingLog:
+		return SlidingLog, nil
+	case SlidingWindowCounter:
+		return SlidingWindowCounter, nil
+	default:
+		return FixedWindow, internal_error.NewBadRequestError("algorithm invalid: " + value)
+	}
+}
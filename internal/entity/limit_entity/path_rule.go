@@ -0,0 +1,57 @@
+package limit_entity
+
+import (
+	"strings"
+
+	"github.com/Higor-ViniciusDev/posgo_raterlimite/internal/internal_error"
+)
+
+// parsePathRules percorre as regras "METODO /caminho=valor" separadas por vírgula, com método opcional,
+// e entrega cada uma ao parse. Regra sem "=" ou com caminho que não começa com / é recusada como
+// "<kind> invalid: <regra>"; o parse valida o próprio valor.
+func parsePathRules(value string, kind string, parse func(method string, path string, value string, part string) *internal_error.InternalError) *internal_error.InternalError {
+	for _, part := range strings.Split(value, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+
+		route, ruleValue, found := strings.Cut(part, "=")
+		if !found {
+			return internal_error.NewBadRequestError(kind + " invalid: " + part)
+		}
+
+		var method string
+		path := strings.TrimSpace(route)
+		if m, p, ok := strings.Cut(path, " "); ok {
+			method = strings.ToUpper(m)
+			path = strings.TrimSpace(p)
+		}
+		if !strings.HasPrefix(path, "/") {
+			return internal_error.NewBadRequestError(kind + " invalid: " + part)
+		}
+
+		if err := parse(method, path, strings.TrimSpace(ruleValue), part); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// matchRoute indica se a regra vale para a requisição: método vazio casa com qualquer um
+// e o caminho casa por segmentos
+func matchRoute(ruleMethod string, rulePath string, method string, path string) bool {
+	if ruleMethod != "" && ruleMethod != method {
+		return false
+	}
+	return matchPath(rulePath, path)
+}
+
+// matchPath compara por segmentos: /export casa /export e /export/csv, mas não /exporter
+func matchPath(rulePath string, path string) bool {
+	if path == rulePath || rulePath == "/" {
+		return true
+	}
+	return strings.HasPrefix(path, strings.TrimSuffix(rulePath, "/")+"/")
+}
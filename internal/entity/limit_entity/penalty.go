@@ -0,0 +1,83 @@
+package limit_entity
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Higor-ViniciusDev/posgo_raterlimite/internal/internal_error"
+)
+
+// PenaltyPolicy escala a penalidade de quem volta a estourar o limite.
+// Sem Steps a penalidade é a constante da strategy (TIME_UNLOCKED_NEW_REQUEST_*).
+type PenaltyPolicy struct {
+	// Steps é a penalidade da 1ª, 2ª, ... infração; a última é o teto
+	Steps []time.Duration
+	// Lookback é o tempo sem infrações para a contagem cair um nível
+	Lookback time.Duration
+}
+
+// NewPenaltyPolicy monta a política; sem lookback a contagem cai a cada intervalo do maior degrau.
+func NewPenaltyPolicy(steps []time.Duration, lookback time.Duration) PenaltyPolicy {
+	if lookback <= 0 && len(steps) > 0 {
+		lookback = steps[len(steps)-1]
+	}
+	return PenaltyPolicy{Steps: steps, Lookback: lookback}
+}
+
+// ParsePenaltySteps converte a lista de segundos separada por vírgula (ex: "1,10,60,3600").
+func ParsePenaltySteps(value string) ([]time.Duration, *internal_error.InternalError) {
+	var steps []time.Duration
+
+	for _, part := range strings.Split(value, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+
+		seconds, err := strconv.Atoi(part)
+		if err != nil || seconds <= 0 {
+			return nil, internal_error.NewBadRequestError("penalty step invalid: " + part)
+		}
+		steps = append(steps, time.Duration(seconds)*time.Second)
+	}
+
+	return steps, nil
+}
+
+// Escalates indica se a penalidade cresce com as infrações
+func (p PenaltyPolicy) Escalates() bool {
+	return len(p.Steps) > 0
+}
+
+// Decay retira uma infração a cada Lookback decorrido desde a última
+func (p PenaltyPolicy) Decay(offenses int64, sinceLast time.Duration) int64 {
+	if p.Lookback <= 0 {
+		return 0
+	}
+
+	offenses -= int64(sinceLast / p.Lookback)
+	if offenses < 0 {
+		return 0
+	}
+	return offenses
+}
+
+// Duration é a penalidade da infração de número offenses (a partir de 1), limitada ao último degrau
+func (p PenaltyPolicy) Duration(offenses int64) time.Duration {
+	if !p.Escalates() || offenses <= 0 {
+		return 0
+	}
+	if offenses > int64(len(p.Steps)) {
+		offenses = int64(len(p.Steps))
+	}
+	return p.Steps[offenses-1]
+}
+
+// Max é a maior penalidade possível
+func (p PenaltyPolicy) Max() time.Duration {
+	if !p.Escalates() {
+		return 0
+	}
+	return p.Steps[len(p.Steps)-1]
+}
@@ -0,0 +1,34 @@
+package limit_entity_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Higor-ViniciusDev/posgo_raterlimite/internal/entity/limit_entity"
+)
+
+func TestPenaltyPolicy_StepsAndDecay(t *testing.T) {
+	steps, err := limit_entity.ParsePenaltySteps("1, 10,60,3600")
+	if err != nil {
+		t.Fatalf("erro ao converter degraus: %v", err)
+	}
+
+	policy := limit_entity.NewPenaltyPolicy(steps, 0)
+	if policy.Lookback != time.Hour {
+		t.Fatalf("sem lookback deveria usar o maior degrau, recebido %v", policy.Lookback)
+	}
+
+	for offenses, expected := range map[int64]time.Duration{1: time.Second, 3: time.Minute, 9: time.Hour} {
+		if got := policy.Duration(offenses); got != expected {
+			t.Fatalf("infração %d: esperado %v, recebido %v", offenses, expected, got)
+		}
+	}
+
+	if got := policy.Decay(3, 90*time.Minute); got != 2 {
+		t.Fatalf("uma hora e meia sem infração deveria cair um nível, recebido %d", got)
+	}
+
+	if _, err := limit_entity.ParsePenaltySteps("1,abc"); err == nil {
+		t.Fatal("degrau inválido deveria retornar erro")
+	}
+}
@@ -0,0 +1,77 @@
+package limit_entity
+
+import (
+	"strings"
+	"time"
+
+	"github.com/Higor-ViniciusDev/posgo_raterlimite/internal/internal_error"
+)
+
+// Plan são os limites de um plano vendido (free, pro, enterprise), aplicados aos tolkens do plano.
+// Campos zerados mantêm o valor da strategy.
+type Plan struct {
+	Limit   int64
+	TTL     time.Duration
+	Penalty time.Duration
+	// ExtraWindows trocam as janelas extras da strategy quando preenchidas
+	ExtraWindows []Window
+}
+
+// ParsePlans converte "nome=limite/janela[/penalidade]" separado por vírgula, com janela e penalidade
+// em duração do Go (ex: "free=10/1s/10s,pro=100/1s").
+func ParsePlans(value string) (map[string]Plan, *internal_error.InternalError) {
+	plans := make(map[string]Plan)
+
+	for _, part := range strings.Split(value, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+
+		name, rules, found := strings.Cut(part, "=")
+		name = strings.TrimSpace(name)
+		if !found || name == "" {
+			return nil, internal_error.NewBadRequestError("plan invalid: " + part)
+		}
+
+		fields := strings.Split(rules, "/")
+		if len(fields) < 2 || len(fields) > 3 {
+			return nil, internal_error.NewBadRequestError("plan invalid: " + part)
+		}
+
+		windows, err := ParseWindows(fields[0] + "/" + fields[1])
+		if err != nil || len(windows) != 1 {
+			return nil, internal_error.NewBadRequestError("plan limit invalid: " + part)
+		}
+		plan := Plan{Limit: windows[0].Limit, TTL: windows[0].TTL}
+
+		if len(fields) > 2 {
+			penalty, parseErr := time.ParseDuration(strings.TrimSpace(fields[2]))
+			if parseErr != nil || penalty < 0 {
+				return nil, internal_error.NewBadRequestError("plan penalty invalid: " + part)
+			}
+			plan.Penalty = penalty
+		}
+
+		plans[name] = plan
+	}
+
+	return plans, nil
+}
+
+// Apply sobrepõe o plano aos limites informados, mantendo o que o plano não define
+func (p Plan) Apply(base Plan) Plan {
+	if p.Limit > 0 {
+		base.Limit = p.Limit
+	}
+	if p.TTL > 0 {
+		base.TTL = p.TTL
+	}
+	if p.Penalty > 0 {
+		base.Penalty = p.Penalty
+	}
+	if len(p.ExtraWindows) > 0 {
+		base.ExtraWindows = p.ExtraWindows
+	}
+	return base
+}
@@ -0,0 +1,37 @@
+package limit_entity_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Higor-ViniciusDev/posgo_raterlimite/internal/entity/limit_entity"
+)
+
+func TestParsePlans(t *testing.T) {
+	plans, err := limit_entity.ParsePlans("free=10/1s/10s, pro=100/1s")
+	if err != nil {
+		t.Fatalf("erro ao converter planos: %v", err)
+	}
+
+	if free := plans["free"]; free.Limit != 10 || free.TTL != time.Second || free.Penalty != 10*time.Second {
+		t.Fatalf("plano free inesperado: %+v", free)
+	}
+	if pro := plans["pro"]; pro.Limit != 100 || pro.Penalty != 0 {
+		t.Fatalf("plano pro inesperado: %+v", pro)
+	}
+
+	for _, invalid := range []string{"free", "=10/1s", "free=10", "free=0/1s", "free=10/1s/x"} {
+		if _, err := limit_entity.ParsePlans(invalid); err == nil {
+			t.Fatalf("%q deveria retornar erro", invalid)
+		}
+	}
+}
+
+func TestPlan_ApplyKeepsUnsetFields(t *testing.T) {
+	base := limit_entity.Plan{Limit: 10, TTL: time.Second, Penalty: 10 * time.Second}
+
+	got := limit_entity.Plan{Limit: 100}.Apply(base)
+	if got.Limit != 100 || got.TTL != time.Second || got.Penalty != 10*time.Second {
+		t.Fatalf("plano deveria trocar apenas o limite: %+v", got)
+	}
+}
@@ -0,0 +1,97 @@
+package limit_entity
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Higor-ViniciusDev/posgo_raterlimite/internal/internal_error"
+)
+
+// RoutePolicy troca as regras da strategy em uma rota. Campos zerados mantêm o valor da strategy.
+// As keys da rota ganham um namespace próprio, então os contadores não se misturam com os das outras rotas.
+type RoutePolicy struct {
+	// Method vazio vale para qualquer método
+	Method string
+	// Path casa com o próprio caminho e com os abaixo dele (/login casa /login e /login/2fa)
+	Path string
+
+	Limit     int64
+	TTL       time.Duration
+	Penalty   time.Duration
+	Algorithm Algorithm
+	Burst     int64
+}
+
+// RoutePolicies são avaliadas na ordem, a primeira que casar vale.
+type RoutePolicies []RoutePolicy
+
+// ParseRoutePolicies converte "METODO /caminho=limite/janela[/penalidade[/algoritmo]]" separado por vírgula,
+// com janela e penalidade em duração do Go (ex: "POST /login=5/1m/5m,GET /search=50/1s").
+func ParseRoutePolicies(value string) (RoutePolicies, *internal_error.InternalError) {
+	var policies RoutePolicies
+
+	err := parsePathRules(value, "route policy", func(method string, path string, rules string, part string) *internal_error.InternalError {
+		policy := RoutePolicy{Method: method, Path: path}
+
+		fields := strings.Split(rules, "/")
+		if len(fields) < 2 || len(fields) > 4 {
+			return internal_error.NewBadRequestError("route policy invalid: " + part)
+		}
+
+		limit, err := strconv.ParseInt(strings.TrimSpace(fields[0]), 10, 64)
+		if err != nil || limit <= 0 {
+			return internal_error.NewBadRequestError("route policy limit invalid: " + part)
+		}
+		policy.Limit = limit
+
+		ttl, err := time.ParseDuration(strings.TrimSpace(fields[1]))
+		if err != nil || ttl <= 0 {
+			return internal_error.NewBadRequestError("route policy window invalid: " + part)
+		}
+		policy.TTL = ttl
+
+		if len(fields) > 2 {
+			penalty, err := time.ParseDuration(strings.TrimSpace(fields[2]))
+			if err != nil || penalty < 0 {
+				return internal_error.NewBadRequestError("route policy penalty invalid: " + part)
+			}
+			policy.Penalty = penalty
+		}
+
+		if len(fields) > 3 {
+			algorithm, algErr := ParseAlgorithm(fields[3])
+			if algErr != nil {
+				return algErr
+			}
+			policy.Algorithm = algorithm
+		}
+
+		policies = append(policies, policy)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return policies, nil
+}
+
+// Match devolve a política da rota, false quando nenhuma casar
+func (r RoutePolicies) Match(method string, path string) (RoutePolicy, bool) {
+	for _, policy := range r {
+		if matchRoute(policy.Method, policy.Path, method, path) {
+			return policy, true
+		}
+	}
+	return RoutePolicy{}, false
+}
+
+// Namespace prefixa a key com a rota (ex: route:POST:/login:ip:10.0.0.1)
+func (p RoutePolicy) Namespace(key string) string {
+	method := p.Method
+	if method == "" {
+		method = "*"
+	}
+	return "route:" + method + ":" + p.Path + ":" + key
+}
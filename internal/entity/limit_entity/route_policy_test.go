@@ -0,0 +1,40 @@
+package limit_entity_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Higor-ViniciusDev/posgo_raterlimite/internal/entity/limit_entity"
+)
+
+func TestRoutePolicies_ParseAndMatch(t *testing.T) {
+	routes, err := limit_entity.ParseRoutePolicies("POST /login=5/1m/5m, /search=50/1s/0s/token_bucket")
+	if err != nil {
+		t.Fatalf("erro ao converter políticas: %v", err)
+	}
+
+	login, ok := routes.Match("POST", "/login")
+	if !ok || login.Limit != 5 || login.TTL != time.Minute || login.Penalty != 5*time.Minute {
+		t.Fatalf("política do login inesperada: %+v", login)
+	}
+	if _, ok := routes.Match("GET", "/login"); ok {
+		t.Fatal("política com método não deveria casar outro método")
+	}
+	if _, ok := routes.Match("POST", "/loginx"); ok {
+		t.Fatal("caminho deveria casar por segmento, não por prefixo de texto")
+	}
+
+	search, ok := routes.Match("GET", "/search/users")
+	if !ok || search.Algorithm != limit_entity.TokenBucket {
+		t.Fatalf("subcaminho deveria casar a política da rota: %+v", search)
+	}
+	if key := search.Namespace("ip:10.0.0.1"); key != "route:*:/search:ip:10.0.0.1" {
+		t.Fatalf("namespace inesperado: %s", key)
+	}
+
+	for _, invalid := range []string{"/login", "/login=5", "login=5/1m", "/login=0/1m", "/login=5/abc"} {
+		if _, err := limit_entity.ParseRoutePolicies(invalid); err == nil {
+			t.Fatalf("política %q deveria ser recusada", invalid)
+		}
+	}
+}
@@ -0,0 +1,27 @@
+package limit_entity
+
+import (
+	"time"
+
+	"github.com/Higor-ViniciusDev/posgo_raterlimite/internal/entity/key_entity"
+)
+
+// StrategyLimits são os limites de uma strategy, já validados pela política carregada na inicialização.
+type StrategyLimits struct {
+	Limit   int64
+	TTL     time.Duration
+	Penalty time.Duration
+	// ExtraWindows são verificadas junto com Limit/TTL
+	ExtraWindows  []Window
+	Algorithm     Algorithm
+	Burst         int64
+	PenaltyPolicy PenaltyPolicy
+	CostRules     CostRules
+	MaxInFlight   int64
+	// Plans são os planos que os tolkens podem ter, pelo nome (só na strategy de tolken)
+	Plans map[string]Plan
+	// IPPrefix agrupa os IPs da mesma rede na key (só na strategy de IP)
+	IPPrefix IPPrefix
+	// KeyExtractor é de onde vem a identificação do cliente, nil usa o padrão da strategy
+	KeyExtractor key_entity.KeyExtractor
+}
@@ -0,0 +1,73 @@
+package limit_entity
+
+import (
+	"strings"
+
+	"github.com/Higor-ViniciusDev/posgo_raterlimite/internal/internal_error"
+)
+
+// TolkenFallback é o que acontece com a requisição quando o tolken enviado não existe ou expirou
+type TolkenFallback string
+
+const (
+	// FallbackReject recusa com 400, o padrão
+	FallbackReject TolkenFallback = "reject"
+	// FallbackIP segue como requisição sem tolken, limitada pela strategy de IP
+	FallbackIP TolkenFallback = "ip"
+	// FallbackUnauthorized recusa com 401 e o header WWW-Authenticate
+	FallbackUnauthorized TolkenFallback = "unauthorized"
+)
+
+// ParseTolkenFallback aceita reject, ip ou unauthorized; vazio é reject
+func ParseTolkenFallback(value string) (TolkenFallback, *internal_error.InternalError) {
+	switch fallback := TolkenFallback(strings.ToLower(strings.TrimSpace(value))); fallback {
+	case "":
+		return FallbackReject, nil
+	case FallbackReject, FallbackIP, FallbackUnauthorized:
+		return fallback, nil
+	default:
+		return "", internal_error.NewBadRequestError("tolken fallback unknown: " + value)
+	}
+}
+
+// TolkenFallbackRule define o fallback de uma rota. Method vazio vale para qualquer método e
+// Path casa com o próprio caminho e com os abaixo dele.
+type TolkenFallbackRule struct {
+	Method   string
+	Path     string
+	Fallback TolkenFallback
+}
+
+// TolkenFallbackRules são avaliadas na ordem, a primeira que casar define o fallback.
+type TolkenFallbackRules []TolkenFallbackRule
+
+// ParseTolkenFallbackRules converte "METODO /caminho=fallback" separado por vírgula
+// (ex: "GET /public=ip,/api=unauthorized", "/=ip" vale para todas as rotas). Vazio recusa com 400 em todas.
+func ParseTolkenFallbackRules(value string) (TolkenFallbackRules, *internal_error.InternalError) {
+	var rules TolkenFallbackRules
+
+	err := parsePathRules(value, "tolken fallback rule", func(method string, path string, fallbackStr string, part string) *internal_error.InternalError {
+		fallback, err := ParseTolkenFallback(fallbackStr)
+		if err != nil {
+			return internal_error.NewBadRequestError("tolken fallback rule invalid: " + part)
+		}
+
+		rules = append(rules, TolkenFallbackRule{Method: method, Path: path, Fallback: fallback})
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return rules, nil
+}
+
+// Fallback devolve o fallback da requisição, FallbackReject quando nenhuma regra casar
+func (r TolkenFallbackRules) Fallback(method string, path string) TolkenFallback {
+	for _, rule := range r {
+		if matchRoute(rule.Method, rule.Path, method, path) {
+			return rule.Fallback
+		}
+	}
+	return FallbackReject
+}
@@ -0,0 +1,41 @@
+package limit_entity_test
+
+import (
+	"testing"
+
+	"github.com/Higor-ViniciusDev/posgo_raterlimite/internal/entity/limit_entity"
+)
+
+func TestTolkenFallbackRules_FirstMatchWins(t *testing.T) {
+	rules, err := limit_entity.ParseTolkenFallbackRules("GET /public=ip, /api=unauthorized, /=reject")
+	if err != nil {
+		t.Fatalf("erro ao converter regras de fallback: %v", err)
+	}
+
+	cases := []struct {
+		method, path string
+		fallback     limit_entity.TolkenFallback
+	}{
+		{"GET", "/public/docs", limit_entity.FallbackIP},
+		{"POST", "/public", limit_entity.FallbackReject},
+		{"GET", "/api/orders", limit_entity.FallbackUnauthorized},
+		{"GET", "/apidocs", limit_entity.FallbackReject},
+		{"GET", "/", limit_entity.FallbackReject},
+	}
+
+	for _, c := range cases {
+		if got := rules.Fallback(c.method, c.path); got != c.fallback {
+			t.Fatalf("%s %s: esperado fallback %s, recebido %s", c.method, c.path, c.fallback, got)
+		}
+	}
+
+	if got := limit_entity.TolkenFallbackRules(nil).Fallback("GET", "/"); got != limit_entity.FallbackReject {
+		t.Fatalf("sem regras deveria recusar, recebido %s", got)
+	}
+
+	for _, value := range []string{"/public=anonymous", "public=ip", "/public"} {
+		if _, err := limit_entity.ParseTolkenFallbackRules(value); err == nil {
+			t.Fatalf("regra %q deveria retornar erro", value)
+		}
+	}
+}
@@ -0,0 +1,43 @@
+package limit_entity
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Higor-ViniciusDev/posgo_raterlimite/internal/internal_error"
+)
+
+// Window é um limite dentro de uma janela, ex: 1000 requisições por hora.
+type Window struct {
+	Limit int64
+	TTL   time.Duration
+}
+
+// ParseWindows converte "limite/janela" separado por vírgula, com a janela em duração do Go
+// (ex: "1000/1h,10000/24h").
+func ParseWindows(value string) ([]Window, *internal_error.InternalError) {
+	var windows []Window
+
+	for _, part := range strings.Split(value, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+
+		limitStr, ttlStr, found := strings.Cut(part, "/")
+		limit, err := strconv.ParseInt(strings.TrimSpace(limitStr), 10, 64)
+		if !found || err != nil || limit <= 0 {
+			return nil, internal_error.NewBadRequestError("window invalid: " + part)
+		}
+
+		ttl, err := time.ParseDuration(strings.TrimSpace(ttlStr))
+		if err != nil || ttl <= 0 {
+			return nil, internal_error.NewBadRequestError("window invalid: " + part)
+		}
+
+		windows = append(windows, Window{Limit: limit, TTL: ttl})
+	}
+
+	return windows, nil
+}
@@ -0,0 +1,31 @@
+package limit_entity_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Higor-ViniciusDev/posgo_raterlimite/internal/entity/limit_entity"
+)
+
+func TestParseWindows(t *testing.T) {
+	windows, err := limit_entity.ParseWindows("1000/1h, 10000/24h")
+	if err != nil {
+		t.Fatalf("erro ao converter janelas: %v", err)
+	}
+
+	expected := []limit_entity.Window{{Limit: 1000, TTL: time.Hour}, {Limit: 10000, TTL: 24 * time.Hour}}
+	if len(windows) != len(expected) {
+		t.Fatalf("esperado %d janelas, recebido %d", len(expected), len(windows))
+	}
+	for i := range expected {
+		if windows[i] != expected[i] {
+			t.Fatalf("janela %d: esperado %+v, recebido %+v", i, expected[i], windows[i])
+		}
+	}
+
+	for _, invalid := range []string{"1000", "0/1h", "1000/1x", "1000/-1h"} {
+		if _, err := limit_entity.ParseWindows(invalid); err == nil {
+			t.Fatalf("%q deveria retornar erro", invalid)
+		}
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"math/rand"
 	"os"
 	"strconv"
+	"time"
 
 	"github.com/Higor-ViniciusDev/posgo_raterlimite/configuration/logger"
 	"github.com/Higor-ViniciusDev/posgo_raterlimite/internal/internal_error"
@@ -16,6 +17,13 @@ type Tolken struct {
 	TokenAuth    *jwtauth.JWTAuth
 	TimeLife     int
 	TolkenString string
+
+	// Plan é o plano do tolken (free, pro...), vazio usa os limites da strategy
+	Plan string
+	// Limit, Window e Penalty explícitos valem sobre o plano; zerados mantêm o plano
+	Limit   int64
+	Window  time.Duration
+	Penalty time.Duration
 }
 
 func NewTolken() *Tolken {
@@ -55,5 +63,7 @@ func (t *Tolken) SetTolkenString() {
 type TolkenRepositoryInterface interface {
 	Save(ctx context.Context, tolken *Tolken) *internal_error.InternalError
 	ValidateTolken(ctx context.Context, tolkenID string) bool
+	// FindTolken devolve o tolken salvo com o plano e os limites, not_found quando não existir
+	FindTolken(ctx context.Context, tolkenID string) (*Tolken, *internal_error.InternalError)
 	DeleteInfoByTolken(ctx context.Context, tolkenID string) *internal_error.InternalError
 }
@@ -0,0 +1,62 @@
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+
+	"github.com/Higor-ViniciusDev/posgo_raterlimite/configuration/rest_err"
+	"github.com/Higor-ViniciusDev/posgo_raterlimite/internal/internal_error"
+	"github.com/Higor-ViniciusDev/posgo_raterlimite/internal/usecase/access_list_usecase"
+)
+
+type AccessListController struct {
+	AccessListUsecase access_list_usecase.AccessListUsecaseInterface
+}
+
+func NewAccessListController(accessListUsecase access_list_usecase.AccessListUsecaseInterface) *AccessListController {
+	return &AccessListController{
+		AccessListUsecase: accessListUsecase,
+	}
+}
+
+// List responde GET /admin/access-list com as duas listas
+func (ac *AccessListController) List(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	output, err := ac.AccessListUsecase.List(r.Context())
+	if err != nil {
+		writeRestError(w, rest_err.ConvertInternalErrorToRestError(err))
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(output)
+}
+
+// Add responde POST /admin/access-list com {"list": "allow|deny", "entry": "IP ou CIDR"}
+func (ac *AccessListController) Add(w http.ResponseWriter, r *http.Request) {
+	ac.change(w, r, ac.AccessListUsecase.Add)
+}
+
+// Remove responde DELETE /admin/access-list com o mesmo corpo do Add
+func (ac *AccessListController) Remove(w http.ResponseWriter, r *http.Request) {
+	ac.change(w, r, ac.AccessListUsecase.Remove)
+}
+
+func (ac *AccessListController) change(w http.ResponseWriter, r *http.Request, apply func(ctx context.Context, input access_list_usecase.EntryInputDTO) *internal_error.InternalError) {
+	w.Header().Set("Content-Type", "application/json")
+
+	var input access_list_usecase.EntryInputDTO
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		writeRestError(w, rest_err.NewBadRequestError("invalid json body"))
+		return
+	}
+
+	if err := apply(r.Context(), input); err != nil {
+		writeRestError(w, rest_err.ConvertInternalErrorToRestError(err))
+		return
+	}
+
+	ac.List(w, r)
+}
@@ -3,6 +3,7 @@ package controller
 import (
 	"context"
 	"encoding/json"
+	"io"
 	"net/http"
 
 	"github.com/Higor-ViniciusDev/posgo_raterlimite/configuration/rest_err"
@@ -19,10 +20,40 @@ func NewTolkenController(tolkenusecase tolken_usecase.TolkenUsecaseInterface) *T
 	}
 }
 
+// CreateTolken responde POST /tolken, público: o tolken usa os limites da strategy
 func (tc *TolkenController) CreateTolken(w http.ResponseWriter, r *http.Request) {
+	tc.create(w, r, false)
+}
+
+// CreateTolkenAdmin responde POST /admin/tolken, atrás do ADMIN-KEY: aceita plano e limites próprios
+func (tc *TolkenController) CreateTolkenAdmin(w http.ResponseWriter, r *http.Request) {
+	tc.create(w, r, true)
+}
+
+func (tc *TolkenController) create(w http.ResponseWriter, r *http.Request, admin bool) {
 	w.Header().Set("Content-Type", "application/json")
 
-	dtoTolkenOutput, err := tc.TolkenUsecase.CreateTolken(context.Background())
+	// o corpo é opcional: sem ele o tolken usa os limites da strategy
+	var input tolken_usecase.CreateTolkenInputDTO
+	if r.Body != nil && r.ContentLength != 0 {
+		if decodeErr := json.NewDecoder(r.Body).Decode(&input); decodeErr != nil && decodeErr != io.EOF {
+			restErro := rest_err.NewBadRequestError("invalid json body")
+
+			w.WriteHeader(restErro.Code)
+			json.NewEncoder(w).Encode(restErro)
+			return
+		}
+	}
+
+	if !admin && input.HasOverrides() {
+		restErro := rest_err.NewForbiddenError("plan and limits require admin key")
+
+		w.WriteHeader(restErro.Code)
+		json.NewEncoder(w).Encode(restErro)
+		return
+	}
+
+	dtoTolkenOutput, err := tc.TolkenUsecase.CreateTolken(context.Background(), input)
 	if err != nil {
 		restErro := rest_err.ConvertInternalErrorToRestError(err)
 
@@ -0,0 +1,84 @@
+package controller_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/Higor-ViniciusDev/posgo_raterlimite/internal/entity/tolken_entity"
+	"github.com/Higor-ViniciusDev/posgo_raterlimite/internal/infra/api/controller"
+	"github.com/Higor-ViniciusDev/posgo_raterlimite/internal/internal_error"
+	"github.com/Higor-ViniciusDev/posgo_raterlimite/internal/usecase/expire_usecase"
+	"github.com/Higor-ViniciusDev/posgo_raterlimite/internal/usecase/tolken_usecase"
+)
+
+// tolkenRepositoryStub guarda os tolkens em memória
+type tolkenRepositoryStub struct {
+	saved map[string]*tolken_entity.Tolken
+}
+
+func (s *tolkenRepositoryStub) Save(ctx context.Context, tolken *tolken_entity.Tolken) *internal_error.InternalError {
+	s.saved[tolken.GetTolkenString()] = tolken
+	return nil
+}
+
+func (s *tolkenRepositoryStub) ValidateTolken(ctx context.Context, tolkenID string) bool {
+	_, ok := s.saved[tolkenID]
+	return ok
+}
+
+func (s *tolkenRepositoryStub) FindTolken(ctx context.Context, tolkenID string) (*tolken_entity.Tolken, *internal_error.InternalError) {
+	tolken, ok := s.saved[tolkenID]
+	if !ok {
+		return nil, internal_error.NewNotFoundError("tolken not found")
+	}
+	return tolken, nil
+}
+
+func (s *tolkenRepositoryStub) DeleteInfoByTolken(ctx context.Context, tolkenID string) *internal_error.InternalError {
+	delete(s.saved, tolkenID)
+	return nil
+}
+
+// planCatalogStub conhece só os planos informados
+type planCatalogStub map[string]bool
+
+func (p planCatalogStub) HasTolkenPlan(name string) bool {
+	return p[name]
+}
+
+func TestTolkenController_PlanRequiresAdmin(t *testing.T) {
+	t.Setenv("JWT_SECRET", "segredo")
+	t.Setenv("TOLKEN_EXPIRATION", "60")
+
+	repo := &tolkenRepositoryStub{saved: map[string]*tolken_entity.Tolken{}}
+	tc := controller.NewTolkenController(tolken_usecase.NewTolkenUsecase(repo, expire_usecase.NewDefaultExpirer(), planCatalogStub{"pro": true}))
+
+	cases := []struct {
+		name    string
+		handler http.HandlerFunc
+		body    string
+		code    int
+	}{
+		{"publico sem corpo", tc.CreateTolken, "", http.StatusOK},
+		{"publico com plano", tc.CreateTolken, `{"plan": "pro"}`, http.StatusForbidden},
+		{"publico com limite", tc.CreateTolken, `{"limit": 1000}`, http.StatusForbidden},
+		{"admin com plano", tc.CreateTolkenAdmin, `{"plan": "pro", "limit": 500}`, http.StatusOK},
+		{"admin com plano desconhecido", tc.CreateTolkenAdmin, `{"plan": "gold"}`, http.StatusBadRequest},
+	}
+
+	for _, c := range cases {
+		rec := httptest.NewRecorder()
+		c.handler(rec, httptest.NewRequest(http.MethodPost, "/tolken", strings.NewReader(c.body)))
+
+		if rec.Code != c.code {
+			t.Fatalf("%s: esperado %d, recebido %d (%s)", c.name, c.code, rec.Code, rec.Body.String())
+		}
+	}
+
+	if len(repo.saved) != 2 {
+		t.Fatalf("só os tolkens aceitos deveriam ser salvos, salvos %d", len(repo.saved))
+	}
+}
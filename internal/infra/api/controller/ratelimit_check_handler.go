@@ -0,0 +1,72 @@
+package controller
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/Higor-ViniciusDev/posgo_raterlimite/configuration/rest_err"
+	"github.com/Higor-ViniciusDev/posgo_raterlimite/internal/usecase/ratelimit_usecase"
+)
+
+type RateLimitController struct {
+	RateLimitUsecase ratelimit_usecase.RateLimitUsecaseInterface
+}
+
+type checkBatchInputDTO struct {
+	Checks []ratelimit_usecase.CheckInputDTO `json:"checks"`
+}
+
+type checkBatchOutputDTO struct {
+	Results []ratelimit_usecase.CheckOutputDTO `json:"results"`
+}
+
+func NewRateLimitController(rateLimitUsecase ratelimit_usecase.RateLimitUsecaseInterface) *RateLimitController {
+	return &RateLimitController{
+		RateLimitUsecase: rateLimitUsecase,
+	}
+}
+
+// Check responde POST /v1/ratelimit/check. A decisão sempre volta com 200; bloqueio é allowed=false.
+func (rc *RateLimitController) Check(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	var input ratelimit_usecase.CheckInputDTO
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		writeRestError(w, rest_err.NewBadRequestError("invalid json body"))
+		return
+	}
+
+	output, err := rc.RateLimitUsecase.Check(r.Context(), input)
+	if err != nil {
+		writeRestError(w, rest_err.ConvertInternalErrorToRestError(err))
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(output)
+}
+
+// CheckBatch responde POST /v1/ratelimit/check/batch com uma decisão por item, na ordem enviada
+func (rc *RateLimitController) CheckBatch(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	var input checkBatchInputDTO
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		writeRestError(w, rest_err.NewBadRequestError("invalid json body"))
+		return
+	}
+
+	results, err := rc.RateLimitUsecase.CheckBatch(r.Context(), input.Checks)
+	if err != nil {
+		writeRestError(w, rest_err.ConvertInternalErrorToRestError(err))
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(checkBatchOutputDTO{Results: results})
+}
+
+func writeRestError(w http.ResponseWriter, restErr *rest_err.RestErr) {
+	w.WriteHeader(restErr.Code)
+	json.NewEncoder(w).Encode(restErr)
+}
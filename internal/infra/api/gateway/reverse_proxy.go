@@ -0,0 +1,74 @@
+package gateway
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"time"
+
+	"github.com/Higor-ViniciusDev/posgo_raterlimite/configuration/logger"
+	"github.com/Higor-ViniciusDev/posgo_raterlimite/configuration/rest_err"
+	"github.com/Higor-ViniciusDev/posgo_raterlimite/internal/internal_error"
+)
+
+// Tempo padrão para o upstream responder os headers
+const DefaultTimeout = 30 * time.Second
+
+// NewReverseProxy encaminha as requisições liberadas pelo rate limiter para o upstream.
+// O httputil.ReverseProxy já remove os headers hop-by-hop (Connection, Keep-Alive, Upgrade...) nos
+// dois sentidos; aqui os X-Forwarded-* são refeitos e os erros do upstream viram 502/504 em RestErr.
+func NewReverseProxy(upstream string, timeout time.Duration) (*httputil.ReverseProxy, *internal_error.InternalError) {
+	target, err := url.Parse(upstream)
+	if err != nil || target.Scheme == "" || target.Host == "" {
+		return nil, internal_error.NewBadRequestError("upstream url invalid: " + upstream)
+	}
+
+	if timeout <= 0 {
+		timeout = DefaultTimeout
+	}
+
+	return &httputil.ReverseProxy{
+		Rewrite: func(pr *httputil.ProxyRequest) {
+			pr.SetURL(target)
+			pr.SetXForwarded()
+			// o Host do upstream, não o do gateway
+			pr.Out.Host = target.Host
+		},
+		Transport: &http.Transport{
+			Proxy: http.ProxyFromEnvironment,
+			DialContext: (&net.Dialer{
+				Timeout:   timeout,
+				KeepAlive: 30 * time.Second,
+			}).DialContext,
+			ResponseHeaderTimeout: timeout,
+			IdleConnTimeout:       90 * time.Second,
+			MaxIdleConnsPerHost:   100,
+		},
+		ErrorHandler: errorHandler,
+	}, nil
+}
+
+// errorHandler responde a falha do upstream: timeout vira 504 e os demais erros 502
+func errorHandler(w http.ResponseWriter, r *http.Request, err error) {
+	// cliente desistiu, não há para quem responder
+	if errors.Is(err, context.Canceled) && r.Context().Err() != nil {
+		return
+	}
+
+	logger.Error(fmt.Sprintf("error proxy upstream %s %s", r.Method, r.URL.Path), err)
+
+	restError := rest_err.NewBadGatewayError("upstream unavailable")
+	var netErr net.Error
+	if errors.Is(err, context.DeadlineExceeded) || (errors.As(err, &netErr) && netErr.Timeout()) {
+		restError = rest_err.NewGatewayTimeoutError("upstream timeout")
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(restError.Code)
+	json.NewEncoder(w).Encode(restError)
+}
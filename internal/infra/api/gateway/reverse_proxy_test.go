@@ -0,0 +1,84 @@
+package gateway_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/Higor-ViniciusDev/posgo_raterlimite/internal/infra/api/gateway"
+)
+
+func TestReverseProxy_ForwardsAndDropsHopByHopHeaders(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Hop") != "" || r.Header.Get("Keep-Alive") != "" {
+			t.Errorf("headers hop-by-hop não deveriam chegar ao upstream: %v", r.Header)
+		}
+		if r.Header.Get("X-Forwarded-For") == "" {
+			t.Errorf("upstream deveria receber o X-Forwarded-For")
+		}
+
+		w.Header().Set("Connection", "X-Upstream-Hop")
+		w.Header().Set("X-Upstream-Hop", "1")
+		w.Header().Set("X-Path", r.URL.Path)
+		w.WriteHeader(http.StatusCreated)
+	}))
+	defer upstream.Close()
+
+	proxy, err := gateway.NewReverseProxy(upstream.URL+"/api", time.Second)
+	if err != nil {
+		t.Fatalf("erro ao criar proxy: %v", err)
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/orders", nil)
+	req.Header.Set("Connection", "X-Hop")
+	req.Header.Set("X-Hop", "1")
+	req.Header.Set("Keep-Alive", "timeout=5")
+	rec := httptest.NewRecorder()
+	proxy.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusCreated || rec.Header().Get("X-Path") != "/api/orders" {
+		t.Fatalf("requisição deveria ser encaminhada para o upstream, recebido %d %v", rec.Code, rec.Header())
+	}
+	if rec.Header().Get("X-Upstream-Hop") != "" {
+		t.Fatal("headers hop-by-hop do upstream não deveriam voltar ao cliente")
+	}
+}
+
+func TestReverseProxy_UpstreamErrors(t *testing.T) {
+	slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(300 * time.Millisecond)
+	}))
+	defer slow.Close()
+
+	down := httptest.NewServer(http.NotFoundHandler())
+	down.Close()
+
+	cases := map[string]struct {
+		upstream string
+		code     int
+	}{
+		"timeout":     {slow.URL, http.StatusGatewayTimeout},
+		"unreachable": {down.URL, http.StatusBadGateway},
+	}
+
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			proxy, err := gateway.NewReverseProxy(c.upstream, 100*time.Millisecond)
+			if err != nil {
+				t.Fatalf("erro ao criar proxy: %v", err)
+			}
+
+			rec := httptest.NewRecorder()
+			proxy.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+
+			if rec.Code != c.code || rec.Header().Get("Content-Type") != "application/json" {
+				t.Fatalf("esperado %d em JSON, recebido %d %s", c.code, rec.Code, rec.Header().Get("Content-Type"))
+			}
+		})
+	}
+
+	if _, err := gateway.NewReverseProxy("localhost:8080", 0); err == nil {
+		t.Fatal("upstream sem scheme deveria ser recusado")
+	}
+}
@@ -48,8 +48,12 @@ func (w WebServer) IniciarWebServer() {
 				handler = infoHandle.Middlewares[i](handler)
 			}
 
-			// Registra a rota com os middlewares aplicados
-			w.Rotas.Method(infoHandle.Metodo, rota, handler)
+			// Registra a rota com os middlewares aplicados, metodo vazio aceita qualquer um (gateway)
+			if infoHandle.Metodo == "" {
+				w.Rotas.Handle(rota, handler)
+			} else {
+				w.Rotas.Method(infoHandle.Metodo, rota, handler)
+			}
 			logger.Info(fmt.Sprintf("Registrando na rota %v com o metodo %v", rota, infoHandle.Metodo))
 		}
 	}
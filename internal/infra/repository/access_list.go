@@ -0,0 +1,69 @@
+package repository
+
+import (
+	"context"
+	"net/netip"
+
+	"github.com/Higor-ViniciusDev/posgo_raterlimite/configuration/logger"
+	"github.com/Higor-ViniciusDev/posgo_raterlimite/internal/entity/access_list_entity"
+	"github.com/Higor-ViniciusDev/posgo_raterlimite/internal/internal_error"
+	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
+)
+
+// Prefixo dos sets do Redis com as listas (access_list:allow e access_list:deny), compartilhados entre as réplicas
+const accessListKeyPrefix = "access_list:"
+
+type AccessListRepository struct {
+	RedisCLI *redis.Client
+}
+
+func NewAccessListRepository(redisCli *redis.Client) *AccessListRepository {
+	return &AccessListRepository{
+		RedisCLI: redisCli,
+	}
+}
+
+func (ar *AccessListRepository) Add(ctx context.Context, list access_list_entity.List, entry netip.Prefix) *internal_error.InternalError {
+	if err := ar.RedisCLI.SAdd(ctx, accessListKeyPrefix+string(list), entry.String()).Err(); err != nil {
+		logger.Error("error add access list redis", err)
+		return internal_error.NewInternalServerError("error add access list redis")
+	}
+	return nil
+}
+
+func (ar *AccessListRepository) Remove(ctx context.Context, list access_list_entity.List, entry netip.Prefix) *internal_error.InternalError {
+	if err := ar.RedisCLI.SRem(ctx, accessListKeyPrefix+string(list), entry.String()).Err(); err != nil {
+		logger.Error("error remove access list redis", err)
+		return internal_error.NewInternalServerError("error remove access list redis")
+	}
+	return nil
+}
+
+func (ar *AccessListRepository) FindAll(ctx context.Context) (*access_list_entity.AccessList, *internal_error.InternalError) {
+	var accessList access_list_entity.AccessList
+
+	for _, list := range []access_list_entity.List{access_list_entity.Allow, access_list_entity.Deny} {
+		members, err := ar.RedisCLI.SMembers(ctx, accessListKeyPrefix+string(list)).Result()
+		if err != nil {
+			logger.Error("error find access list redis", err)
+			return nil, internal_error.NewInternalServerError("error find access list redis")
+		}
+
+		for _, member := range members {
+			prefix, parseErr := access_list_entity.ParseEntry(member)
+			if parseErr != nil {
+				logger.Info("entrada invalida na access list ignorada", zap.String("list", string(list)), zap.String("entry", member))
+				continue
+			}
+
+			if list == access_list_entity.Allow {
+				accessList.Allow = append(accessList.Allow, prefix)
+			} else {
+				accessList.Deny = append(accessList.Deny, prefix)
+			}
+		}
+	}
+
+	return &accessList, nil
+}
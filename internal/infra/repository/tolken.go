@@ -2,6 +2,7 @@ package repository
 
 import (
 	"context"
+	"strconv"
 	"time"
 
 	"github.com/Higor-ViniciusDev/posgo_raterlimite/configuration/logger"
@@ -27,6 +28,19 @@ func (tb *TolkenRepository) Save(ctx context.Context, tolken *tolken_entity.Tolk
 		"ttl":      tolken.TimeLife,
 		"start_at": time.Now().Unix(),
 	}
+	// plano e limites só são gravados quando definidos
+	if tolken.Plan != "" {
+		data["plan"] = tolken.Plan
+	}
+	if tolken.Limit > 0 {
+		data["limit"] = tolken.Limit
+	}
+	if tolken.Window > 0 {
+		data["window_ms"] = tolken.Window.Milliseconds()
+	}
+	if tolken.Penalty > 0 {
+		data["penalty_ms"] = tolken.Penalty.Milliseconds()
+	}
 
 	cmd := tb.RediDB.HSet(ctx, tolkenID, data)
 	if cmd.Err() != nil {
@@ -51,6 +65,31 @@ func (tb *TolkenRepository) ValidateTolken(ctx context.Context, tolkenID string)
 	return false
 }
 
+func (tb *TolkenRepository) FindTolken(ctx context.Context, tolkenID string) (*tolken_entity.Tolken, *internal_error.InternalError) {
+	data, err := tb.RediDB.HGetAll(ctx, tolkenID).Result()
+	if err != nil {
+		logger.Error("error find tolken redis", err)
+		return nil, internal_error.NewInternalServerError("error find tolken redis")
+	}
+	if len(data) == 0 {
+		return nil, internal_error.NewNotFoundError("tolken not found")
+	}
+
+	ttl, _ := strconv.Atoi(data["ttl"])
+	limit, _ := strconv.ParseInt(data["limit"], 10, 64)
+	window, _ := strconv.ParseInt(data["window_ms"], 10, 64)
+	penalty, _ := strconv.ParseInt(data["penalty_ms"], 10, 64)
+
+	return &tolken_entity.Tolken{
+		TolkenString: tolkenID,
+		TimeLife:     ttl,
+		Plan:         data["plan"],
+		Limit:        limit,
+		Window:       time.Duration(window) * time.Millisecond,
+		Penalty:      time.Duration(penalty) * time.Millisecond,
+	}, nil
+}
+
 func (tb *TolkenRepository) DeleteInfoByTolken(ctx context.Context, tolkenID string) *internal_error.InternalError {
 	err := tb.RediDB.Del(ctx, tolkenID).Err()
 	logger.Info("chegou deletando key: " + tolkenID)
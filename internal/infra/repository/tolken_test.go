@@ -0,0 +1,60 @@
+package repository_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/Higor-ViniciusDev/posgo_raterlimite/internal/entity/tolken_entity"
+	"github.com/Higor-ViniciusDev/posgo_raterlimite/internal/infra/repository"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+func newTolkenRepository(t *testing.T) *repository.TolkenRepository {
+	t.Helper()
+
+	mr, err := miniredis.Run()
+	if err != nil {
+		t.Fatalf("erro ao iniciar miniredis: %v", err)
+	}
+	t.Cleanup(mr.Close)
+
+	return repository.NewTolkenDB(redis.NewClient(&redis.Options{Addr: mr.Addr()}))
+}
+
+func TestTolkenRepository_SaveAndFind(t *testing.T) {
+	repo := newTolkenRepository(t)
+	ctx := context.Background()
+
+	tolkens := []*tolken_entity.Tolken{
+		{TolkenString: "sem-plano", TimeLife: 60},
+		{TolkenString: "pro", TimeLife: 120, Plan: "pro"},
+		{TolkenString: "completo", TimeLife: 30, Plan: "pro", Limit: 500, Window: 90 * time.Second, Penalty: 1500 * time.Millisecond},
+	}
+
+	for _, tolken := range tolkens {
+		if err := repo.Save(ctx, tolken); err != nil {
+			t.Fatalf("erro ao salvar %s: %v", tolken.TolkenString, err)
+		}
+
+		found, err := repo.FindTolken(ctx, tolken.TolkenString)
+		if err != nil {
+			t.Fatalf("erro ao buscar %s: %v", tolken.TolkenString, err)
+		}
+		if found.TolkenString != tolken.TolkenString || found.TimeLife != tolken.TimeLife || found.Plan != tolken.Plan ||
+			found.Limit != tolken.Limit || found.Window != tolken.Window || found.Penalty != tolken.Penalty {
+			t.Fatalf("tolken salvo %+v voltou diferente: %+v", tolken, found)
+		}
+	}
+
+	if !repo.ValidateTolken(ctx, "pro") {
+		t.Fatal("tolken salvo deveria ser válido")
+	}
+	if err := repo.DeleteInfoByTolken(ctx, "pro"); err != nil {
+		t.Fatalf("erro ao remover: %v", err)
+	}
+	if _, err := repo.FindTolken(ctx, "pro"); err == nil || err.Err != "not_found" {
+		t.Fatalf("tolken removido deveria ser not_found, recebido %v", err)
+	}
+}
@@ -0,0 +1,68 @@
+package interceptor
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/Higor-ViniciusDev/posgo_raterlimite/internal/keyresolver"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+)
+
+type grpcSource struct {
+	md   metadata.MD
+	addr string
+}
+
+// FromGRPC lê a metadata recebida (api-key, x-real-ip, ...) e o endereço do peer da chamada
+func FromGRPC(ctx context.Context) keyresolver.Source {
+	src := grpcSource{}
+	src.md, _ = metadata.FromIncomingContext(ctx)
+
+	if p, ok := peer.FromContext(ctx); ok && p.Addr != nil {
+		src.addr = p.Addr.String()
+	}
+
+	return src
+}
+
+// Header busca na metadata, que o gRPC guarda sempre em minúsculas
+func (s grpcSource) Header(name string) string {
+	if values := s.md.Get(name); len(values) > 0 {
+		return values[0]
+	}
+	return ""
+}
+
+// Headers converte a metadata para o formato do HTTP (x-forwarded-for vira X-Forwarded-For)
+func (s grpcSource) Headers() http.Header {
+	headers := make(http.Header, len(s.md))
+	for name, values := range s.md {
+		for _, value := range values {
+			headers.Add(name, value)
+		}
+	}
+	return headers
+}
+
+// Query não existe no gRPC
+func (s grpcSource) Query(name string) string {
+	return ""
+}
+
+// Cookie lê a metadata cookie, quando o cliente gRPC-Web ou o proxy repassar
+func (s grpcSource) Cookie(name string) string {
+	cookie, err := (&http.Request{Header: s.Headers()}).Cookie(name)
+	if err != nil {
+		return ""
+	}
+	return cookie.Value
+}
+
+func (s grpcSource) RemoteAddr() string {
+	return s.addr
+}
+
+func (s grpcSource) ClientIP() string {
+	return keyresolver.ClientIP(s)
+}
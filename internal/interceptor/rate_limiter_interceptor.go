@@ -0,0 +1,159 @@
+package interceptor
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+
+	"github.com/Higor-ViniciusDev/posgo_raterlimite/configuration/logger"
+	"github.com/Higor-ViniciusDev/posgo_raterlimite/internal/entity/access_list_entity"
+	"github.com/Higor-ViniciusDev/posgo_raterlimite/internal/internal_error"
+	"github.com/Higor-ViniciusDev/posgo_raterlimite/internal/keyresolver"
+	"github.com/Higor-ViniciusDev/posgo_raterlimite/internal/ratelimiter"
+	"github.com/Higor-ViniciusDev/posgo_raterlimite/internal/usecase/access_list_usecase"
+	"github.com/Higor-ViniciusDev/posgo_raterlimite/internal/usecase/policy_usecase"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/durationpb"
+)
+
+// Option configura os interceptors, como as options do RateLimiterMiddleware
+type Option func(*options)
+
+type options struct {
+	accessList access_list_usecase.AccessListUsecaseInterface
+}
+
+// WithAccessList consulta as listas de IP antes de resolver a strategy: IP na allow passa sem rate limit
+// e IP na deny recebe PermissionDenied, como o 403 do middleware
+func WithAccessList(accessList access_list_usecase.AccessListUsecaseInterface) Option {
+	return func(o *options) {
+		o.accessList = accessList
+	}
+}
+
+func newOptions(opts []Option) options {
+	var o options
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
+
+// UnaryRateLimiterInterceptor é o RateLimiterMiddleware para chamadas unary do gRPC: a key vem da
+// metadata api-key ou do IP do peer e a chamada bloqueada volta com ResourceExhausted e RetryInfo.
+func UnaryRateLimiterInterceptor(policy *policy_usecase.PolicyUsecase, limiter ratelimiter.Limiter, opts ...Option) grpc.UnaryServerInterceptor {
+	o := newOptions(opts)
+
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		if err := allow(ctx, policy, limiter, info.FullMethod, o); err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+}
+
+// StreamRateLimiterInterceptor decide uma vez na abertura do stream, com a mesma regra do unary
+func StreamRateLimiterInterceptor(policy *policy_usecase.PolicyUsecase, limiter ratelimiter.Limiter, opts ...Option) grpc.StreamServerInterceptor {
+	o := newOptions(opts)
+
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		if err := allow(ss.Context(), policy, limiter, info.FullMethod, o); err != nil {
+			return err
+		}
+		return handler(srv, ss)
+	}
+}
+
+// allow segue o mesmo caminho do RateLimiterMiddleware: lista de IP, cadeia da política, política da rota e AllowAll
+func allow(ctx context.Context, policy *policy_usecase.PolicyUsecase, limiter ratelimiter.Limiter, fullMethod string, o options) error {
+	src := FromGRPC(ctx)
+
+	if o.accessList != nil {
+		switch o.accessList.Check(keyresolver.ClientIP(src)) {
+		case access_list_entity.Denied:
+			return toStatus(internal_error.NewForbiddenError("ip address is denied"))
+		case access_list_entity.Allowed:
+			return nil
+		}
+	}
+
+	snapshot := policy.Snapshot()
+
+	// o método gRPC é o caminho da rota, como no custo por rota
+	req := methodRequest(fullMethod)
+	resolved, err := keyresolver.ResolveAll(ctx, snapshot, src, snapshot.MatchTolkenFallback(req.Method, req.URL.Path))
+	if err != nil {
+		return toStatus(err)
+	}
+
+	route, ok := snapshot.MatchRoute(req.Method, req.URL.Path)
+	checks := ratelimiter.NewChecks(req, resolved, route, ok)
+
+	decision, _, err := limiter.AllowAll(ctx, checks)
+	if err != nil {
+		return toStatus(err)
+	}
+
+	if !decision.Allowed {
+		return resourceExhausted(decision)
+	}
+
+	//Mesmo requisito do middleware: apenas as liberadas são salvas
+	for i, item := range resolved {
+		go saveRequestInfo(item.Strategy, checks[i].Key)
+	}
+
+	return nil
+}
+
+// methodRequest representa a chamada como o HTTP/2 que o gRPC usa por baixo (POST /pacote.Servico/Metodo),
+// assim as regras de custo por rota também valem para os métodos gRPC.
+func methodRequest(fullMethod string) *http.Request {
+	return &http.Request{Method: http.MethodPost, URL: &url.URL{Path: fullMethod}}
+}
+
+// resourceExhausted é o equivalente ao 429: a mensagem do rate limit com o tempo de espera em RetryInfo
+func resourceExhausted(decision ratelimiter.Decision) error {
+	st := status.New(codes.ResourceExhausted, ratelimiter.NewLimitExceededError().Message)
+
+	retryAfter := decision.RetryAfter()
+	if retryAfter <= 0 {
+		return st.Err()
+	}
+
+	detailed, err := st.WithDetails(&errdetails.RetryInfo{RetryDelay: durationpb.New(retryAfter)})
+	if err != nil {
+		logger.Error("erro ao adicionar retry info no status", err)
+		return st.Err()
+	}
+	return detailed.Err()
+}
+
+// toStatus converte o InternalError no código gRPC equivalente ao status HTTP do rest_err
+func toStatus(err *internal_error.InternalError) error {
+	switch err.Err {
+	case "bad_request":
+		return status.Error(codes.InvalidArgument, err.Message)
+	case "not_found":
+		return status.Error(codes.NotFound, err.Message)
+	case "many_request":
+		return status.Error(codes.ResourceExhausted, err.Message)
+	case "request_timeout":
+		return status.Error(codes.DeadlineExceeded, err.Message)
+	case "forbidden":
+		return status.Error(codes.PermissionDenied, err.Message)
+	case "unauthorized":
+		return status.Error(codes.Unauthenticated, err.Message)
+	default:
+		return status.Error(codes.Internal, err.Message)
+	}
+}
+
+func saveRequestInfo(strategy policy_usecase.RateLimitStrategy, key string) {
+	if err := strategy.SaveRequestInfo(context.Background(), key); err != nil {
+		logger.Error("erro ao salvar request info", err)
+	}
+}
@@ -0,0 +1,208 @@
+package interceptor_test
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/Higor-ViniciusDev/posgo_raterlimite/configuration/policy"
+	"github.com/Higor-ViniciusDev/posgo_raterlimite/internal/entity/limit_entity"
+	"github.com/Higor-ViniciusDev/posgo_raterlimite/internal/infra/repository"
+	"github.com/Higor-ViniciusDev/posgo_raterlimite/internal/interceptor"
+	"github.com/Higor-ViniciusDev/posgo_raterlimite/internal/keyresolver"
+	"github.com/Higor-ViniciusDev/posgo_raterlimite/internal/ratelimiter"
+	"github.com/Higor-ViniciusDev/posgo_raterlimite/internal/usecase/access_list_usecase"
+	"github.com/Higor-ViniciusDev/posgo_raterlimite/internal/usecase/policy_usecase"
+	"github.com/Higor-ViniciusDev/posgo_raterlimite/internal/usecase/strategy_usecase"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+// envLimits lê os limites da strategy do env atual, como a inicialização do serviço
+func envLimits(t *testing.T, suffix string) limit_entity.StrategyLimits {
+	t.Helper()
+
+	strategy, err := policy.StrategyFromEnv(suffix)
+	if err != nil {
+		t.Fatalf("variaveis invalidas para strategy %s: %v", suffix, err)
+	}
+	return strategy.Limits()
+}
+
+func newTestPolicy(t *testing.T) *policy_usecase.PolicyUsecase {
+	t.Helper()
+
+	// a janela fixa recusa a requisição que atinge o limite: o IP admite 2 chamadas
+	t.Setenv("REQUEST_PER_SECOND_IP", "3")
+	t.Setenv("TLL_KEY_IP", "10")
+	t.Setenv("TIME_UNLOCKED_NEW_REQUEST_IP", "0")
+
+	mr, err := miniredis.Run()
+	if err != nil {
+		t.Fatalf("erro ao iniciar miniredis: %v", err)
+	}
+	t.Cleanup(mr.Close)
+
+	redisClient := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	requestInfoRepository := repository.NewRequestInfoRepository(redisClient)
+
+	return &policy_usecase.PolicyUsecase{
+		TokenStrategy: strategy_usecase.NewTokenStrategyUsecaseWithLimits(envLimits(t, policy.EnvTolken), repository.NewTolkenDB(redisClient), requestInfoRepository),
+		IPStrategy:    strategy_usecase.NewIPStrategyUsecaseWithLimits(envLimits(t, policy.EnvIP), requestInfoRepository),
+	}
+}
+
+// peerContext simula a chamada recebida de um peer, com a metadata informada
+func peerContext(ip string, pairs ...string) context.Context {
+	ctx := peer.NewContext(context.Background(), &peer.Peer{Addr: &net.TCPAddr{IP: net.ParseIP(ip), Port: 5000}})
+	return metadata.NewIncomingContext(ctx, metadata.Pairs(pairs...))
+}
+
+func TestUnaryRateLimiterInterceptor_ResourceExhausted(t *testing.T) {
+	unary := interceptor.UnaryRateLimiterInterceptor(newTestPolicy(t), ratelimiter.NewLocalLimiter(0))
+	info := &grpc.UnaryServerInfo{FullMethod: "/echo.Echo/Say"}
+	handler := func(ctx context.Context, req any) (any, error) { return "ok", nil }
+
+	for i := 0; i < 2; i++ {
+		if _, err := unary(peerContext("10.0.0.8"), nil, info, handler); err != nil {
+			t.Fatalf("chamada %d dentro do limite deveria passar: %v", i+1, err)
+		}
+	}
+
+	_, err := unary(peerContext("10.0.0.8"), nil, info, handler)
+	st, _ := status.FromError(err)
+	if st.Code() != codes.ResourceExhausted {
+		t.Fatalf("chamada acima do limite deveria ser ResourceExhausted, recebido %v", err)
+	}
+
+	var retry *errdetails.RetryInfo
+	for _, detail := range st.Details() {
+		if info, ok := detail.(*errdetails.RetryInfo); ok {
+			retry = info
+		}
+	}
+	if retry == nil || retry.RetryDelay.AsDuration() <= 0 {
+		t.Fatalf("status deveria trazer RetryInfo, detalhes %v", st.Details())
+	}
+
+	// sem proxy configurado a metadata não muda o cliente
+	if _, err := unary(peerContext("10.0.0.8", "x-real-ip", "203.0.113.8"), nil, info, handler); status.Code(err) != codes.ResourceExhausted {
+		t.Fatalf("metadata de peer não confiável deveria ser ignorada, recebido %v", err)
+	}
+
+	// com o peer como proxy confiável a metadata muda o cliente, como os headers no HTTP
+	resolver, resolverErr := keyresolver.NewClientIPResolver(keyresolver.ClientIPConfig{
+		Strategy:       keyresolver.ClientIPHeader,
+		Header:         "X-Real-IP",
+		TrustedProxies: []string{"10.0.0.0/8"},
+	})
+	if resolverErr != nil {
+		t.Fatalf("erro ao configurar o IP do cliente: %v", resolverErr)
+	}
+	keyresolver.SetClientIPResolver(resolver)
+	t.Cleanup(func() { keyresolver.SetClientIPResolver(nil) })
+
+	if _, err := unary(peerContext("10.0.0.8", "x-real-ip", "203.0.113.8"), nil, info, handler); err != nil {
+		t.Fatalf("outro cliente deveria ter a própria cota: %v", err)
+	}
+}
+
+func TestUnaryRateLimiterInterceptor_InvalidTolken(t *testing.T) {
+	unary := interceptor.UnaryRateLimiterInterceptor(newTestPolicy(t), ratelimiter.NewLocalLimiter(0))
+	info := &grpc.UnaryServerInfo{FullMethod: "/echo.Echo/Say"}
+	handler := func(ctx context.Context, req any) (any, error) { return "ok", nil }
+
+	_, err := unary(peerContext("10.0.0.9", "api-key", "missing"), nil, info, handler)
+	if status.Code(err) != codes.InvalidArgument {
+		t.Fatalf("tolken inexistente deveria ser InvalidArgument, recebido %v", err)
+	}
+}
+
+func TestUnaryRateLimiterInterceptor_AccessListAndRoutes(t *testing.T) {
+	mr, err := miniredis.Run()
+	if err != nil {
+		t.Fatalf("erro ao iniciar miniredis: %v", err)
+	}
+	t.Cleanup(mr.Close)
+
+	accessList := access_list_usecase.NewAccessListUsecase(repository.NewAccessListRepository(redis.NewClient(&redis.Options{Addr: mr.Addr()})), time.Hour)
+	for _, input := range []access_list_usecase.EntryInputDTO{
+		{List: "allow", Entry: "10.20.0.0/16"},
+		{List: "deny", Entry: "10.30.0.1"},
+	} {
+		if err := accessList.Add(context.Background(), input); err != nil {
+			t.Fatalf("erro ao adicionar %+v: %v", input, err)
+		}
+	}
+
+	policy := newTestPolicy(t)
+	routes, routeErr := limit_entity.ParseRoutePolicies("POST /auth.Auth/Login=2/10s")
+	if routeErr != nil {
+		t.Fatalf("rota válida recusada: %v", routeErr)
+	}
+	policy.Routes = routes
+
+	unary := interceptor.UnaryRateLimiterInterceptor(policy, ratelimiter.NewLocalLimiter(0), interceptor.WithAccessList(accessList))
+	handler := func(ctx context.Context, req any) (any, error) { return "ok", nil }
+	call := func(ip string, method string) error {
+		_, err := unary(peerContext(ip), nil, &grpc.UnaryServerInfo{FullMethod: method}, handler)
+		return err
+	}
+
+	// deny recebe PermissionDenied e allow passa sem limite
+	if err := call("10.30.0.1", "/echo.Echo/Say"); status.Code(err) != codes.PermissionDenied {
+		t.Fatalf("IP negado deveria receber PermissionDenied, recebido %v", err)
+	}
+	for i := 0; i < 5; i++ {
+		if err := call("10.20.1.1", "/auth.Auth/Login"); err != nil {
+			t.Fatalf("IP liberado deveria passar sem rate limit: %v", err)
+		}
+	}
+
+	// a rota vale sobre a strategy (2 em vez de 3) e tem o próprio contador
+	if err := call("10.0.0.11", "/auth.Auth/Login"); err != nil {
+		t.Fatalf("primeira chamada da rota deveria passar: %v", err)
+	}
+	if err := call("10.0.0.11", "/auth.Auth/Login"); status.Code(err) != codes.ResourceExhausted {
+		t.Fatalf("a rota deveria admitir só 1 chamada, recebido %v", err)
+	}
+	if err := call("10.0.0.11", "/echo.Echo/Say"); err != nil {
+		t.Fatalf("fora da rota vale a cota da strategy: %v", err)
+	}
+}
+
+type fakeStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s fakeStream) Context() context.Context {
+	return s.ctx
+}
+
+func TestStreamRateLimiterInterceptor_ChecksOnOpen(t *testing.T) {
+	stream := interceptor.StreamRateLimiterInterceptor(newTestPolicy(t), ratelimiter.NewLocalLimiter(0))
+	info := &grpc.StreamServerInfo{FullMethod: "/echo.Echo/Watch", IsServerStream: true}
+
+	opened := 0
+	handler := func(srv any, ss grpc.ServerStream) error {
+		opened++
+		return nil
+	}
+
+	var err error
+	for i := 0; i < 3; i++ {
+		err = stream(nil, fakeStream{ctx: peerContext("10.0.0.10")}, info, handler)
+	}
+
+	if opened != 2 || status.Code(err) != codes.ResourceExhausted {
+		t.Fatalf("apenas 2 streams deveriam abrir, abertos %d, último erro %v", opened, err)
+	}
+}
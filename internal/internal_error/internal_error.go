@@ -36,3 +36,24 @@ func NewManyRequestError(message string) *InternalError {
 		Err:     "many_request",
 	}
 }
+
+func NewRequestTimeoutError(message string) *InternalError {
+	return &InternalError{
+		Message: message,
+		Err:     "request_timeout",
+	}
+}
+
+func NewForbiddenError(message string) *InternalError {
+	return &InternalError{
+		Message: message,
+		Err:     "forbidden",
+	}
+}
+
+func NewUnauthorizedError(message string) *InternalError {
+	return &InternalError{
+		Message: message,
+		Err:     "unauthorized",
+	}
+}
@@ -0,0 +1,139 @@
+package keyresolver
+
+import (
+	"net"
+	"strings"
+	"sync/atomic"
+
+	"github.com/Higor-ViniciusDev/posgo_raterlimite/internal/internal_error"
+	"github.com/realclientip/realclientip-go"
+)
+
+// Estratégias para achar o IP do cliente (CLIENT_IP_STRATEGY)
+const (
+	// ClientIPRemoteAddr usa só o endereço da conexão, sem acreditar em header nenhum (padrão)
+	ClientIPRemoteAddr = "remote_addr"
+	// ClientIPTrustedProxies percorre o X-Forwarded-For da direita para a esquerda pulando os proxies confiáveis
+	ClientIPTrustedProxies = "trusted_proxies"
+	// ClientIPRightmostNonPrivate usa o último IP público do X-Forwarded-For (proxies na rede interna)
+	ClientIPRightmostNonPrivate = "rightmost_non_private"
+	// ClientIPHeader usa um único header com o IP (X-Real-IP, CF-Connecting-IP...) preenchido pelo proxy
+	ClientIPHeader = "header"
+)
+
+// ClientIPConfig configura de onde vem o IP do cliente.
+// TrustedProxies são os IPs/CIDRs dos proxies na frente do serviço: os headers só valem quando a conexão
+// vem de um deles (obrigatório em trusted_proxies, opcional nas outras estratégias com header).
+// Header é o header lido (X-Forwarded-For ou Forwarded nas estratégias de lista, obrigatório em header).
+type ClientIPConfig struct {
+	Strategy       string
+	TrustedProxies []string
+	Header         string
+}
+
+// ClientIPResolver acha o IP do cliente acreditando só nos headers que vieram de saltos confiáveis
+type ClientIPResolver struct {
+	trusted  []net.IPNet
+	strategy realclientip.Strategy
+}
+
+// NewClientIPResolver valida a configuração, estratégia vazia usa remote_addr
+func NewClientIPResolver(config ClientIPConfig) (*ClientIPResolver, *internal_error.InternalError) {
+	var entries []string
+	for _, entry := range config.TrustedProxies {
+		if entry = strings.TrimSpace(entry); entry != "" {
+			entries = append(entries, entry)
+		}
+	}
+
+	trusted, err := realclientip.AddressesAndRangesToIPNets(entries...)
+	if err != nil {
+		return nil, internal_error.NewBadRequestError("trusted proxies invalid: " + err.Error())
+	}
+
+	resolver := &ClientIPResolver{trusted: trusted}
+	header := strings.TrimSpace(config.Header)
+
+	switch strings.ToLower(strings.TrimSpace(config.Strategy)) {
+	case "", ClientIPRemoteAddr:
+		return resolver, nil
+	case ClientIPTrustedProxies:
+		if len(trusted) == 0 {
+			return nil, internal_error.NewBadRequestError("client ip strategy trusted_proxies needs trusted proxies")
+		}
+		resolver.strategy, err = realclientip.NewRightmostTrustedRangeStrategy(listHeader(header), trusted)
+	case ClientIPRightmostNonPrivate:
+		resolver.strategy, err = realclientip.NewRightmostNonPrivateStrategy(listHeader(header))
+	case ClientIPHeader:
+		resolver.strategy, err = realclientip.NewSingleIPHeaderStrategy(header)
+	default:
+		return nil, internal_error.NewBadRequestError("client ip strategy invalid: " + config.Strategy)
+	}
+
+	if err != nil {
+		return nil, internal_error.NewBadRequestError("client ip header invalid: " + err.Error())
+	}
+	return resolver, nil
+}
+
+func listHeader(header string) string {
+	if header == "" {
+		return "X-Forwarded-For"
+	}
+	return header
+}
+
+// ClientIP devolve o IP do cliente, vazio quando nenhum IP válido for encontrado.
+// Conexão que não vem de um proxy confiável (quando há proxies configurados) fica com o próprio endereço,
+// assim um cliente direto não escolhe o IP mandando o header.
+func (c *ClientIPResolver) ClientIP(src Source) string {
+	remote := normalizeIP(realclientip.RemoteAddrStrategy{}.ClientIP(nil, src.RemoteAddr()))
+	if c == nil || c.strategy == nil {
+		return remote
+	}
+
+	if len(c.trusted) > 0 && !c.isTrusted(remote) {
+		return remote
+	}
+
+	if ip := normalizeIP(c.strategy.ClientIP(src.Headers(), src.RemoteAddr())); ip != "" {
+		return ip
+	}
+	return remote
+}
+
+func (c *ClientIPResolver) isTrusted(ip string) bool {
+	parsed := net.ParseIP(ip)
+	if parsed == nil {
+		return false
+	}
+	for _, ipNet := range c.trusted {
+		if ipNet.Contains(parsed) {
+			return true
+		}
+	}
+	return false
+}
+
+// normalizeIP tira a zona do IPv6 e devolve o IP no formato canônico
+func normalizeIP(ip string) string {
+	host, _ := realclientip.SplitHostZone(ip)
+	parsed := net.ParseIP(host)
+	if parsed == nil {
+		return ""
+	}
+	return parsed.String()
+}
+
+// resolver usado por ClientIP, trocado na inicialização pela configuração do serviço
+var clientIPResolver atomic.Pointer[ClientIPResolver]
+
+// SetClientIPResolver troca a forma de achar o IP do cliente no HTTP e no gRPC, nil volta para remote_addr
+func SetClientIPResolver(resolver *ClientIPResolver) {
+	clientIPResolver.Store(resolver)
+}
+
+// ClientIP resolve o IP do cliente com o resolver configurado em SetClientIPResolver
+func ClientIP(src Source) string {
+	return clientIPResolver.Load().ClientIP(src)
+}
@@ -0,0 +1,102 @@
+package keyresolver
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/Higor-ViniciusDev/posgo_raterlimite/internal/entity/key_entity"
+	"github.com/Higor-ViniciusDev/posgo_raterlimite/internal/entity/limit_entity"
+	"github.com/Higor-ViniciusDev/posgo_raterlimite/internal/internal_error"
+	"github.com/Higor-ViniciusDev/posgo_raterlimite/internal/usecase/policy_usecase"
+)
+
+// Source é de onde o resolver lê os dados do cliente em cada transporte
+// (headers, query e cookies no HTTP, metadata no gRPC).
+type Source = key_entity.Source
+
+// ResolveAll monta o input com o KeyExtractor de cada strategy (API-KEY e IP do cliente por padrão) e devolve
+// cada strategy aplicável da cadeia com a key já gerada, na ordem da cadeia. É a mesma regra para HTTP e gRPC,
+// tudo lido do mesmo snapshot da política. Qualquer key inválida (ex: tolken não encontrado) recusa a requisição
+// inteira, a não ser que o fallback da rota mande o tolken inválido seguir pela strategy de IP.
+func ResolveAll(ctx context.Context, policy *policy_usecase.Snapshot, src Source, fallback limit_entity.TolkenFallback) ([]policy_usecase.ResolvedStrategy, *internal_error.InternalError) {
+	input := policy.Input(src)
+
+	resolved := policy.ResolveChain(input)
+	failed, err := generateKeys(ctx, resolved)
+	if err != nil && failed.Name == limit_entity.ChainTolken {
+		if err = tolkenFallback(err, fallback); err == nil {
+			input.Tolken = ""
+			resolved = policy.ResolveChain(input)
+			_, err = generateKeys(ctx, resolved)
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return resolved, nil
+}
+
+// generateKeys troca o valor extraído de cada strategy pela key gerada, com os limites dela, e devolve a que falhou
+func generateKeys(ctx context.Context, resolved []policy_usecase.ResolvedStrategy) (policy_usecase.ResolvedStrategy, *internal_error.InternalError) {
+	for i, item := range resolved {
+		key, limits, err := item.Strategy.GenerateKey(ctx, item.Key)
+		if err != nil {
+			return item, err
+		}
+		resolved[i].Key = key
+		resolved[i].Limits = limits
+	}
+	return policy_usecase.ResolvedStrategy{}, nil
+}
+
+// tolkenFallback aplica o fallback ao erro do tolken: nil segue sem o tolken (FallbackIP), unauthorized
+// vira 401 e reject mantém o erro da strategy
+func tolkenFallback(err *internal_error.InternalError, fallback limit_entity.TolkenFallback) *internal_error.InternalError {
+	switch fallback {
+	case limit_entity.FallbackIP:
+		return nil
+	case limit_entity.FallbackUnauthorized:
+		return internal_error.NewUnauthorizedError(err.Message)
+	default:
+		return err
+	}
+}
+
+type httpSource struct {
+	r *http.Request
+}
+
+// FromHTTP lê os headers e o RemoteAddr da requisição HTTP
+func FromHTTP(r *http.Request) Source {
+	return httpSource{r: r}
+}
+
+func (s httpSource) Header(name string) string {
+	return s.r.Header.Get(name)
+}
+
+func (s httpSource) Headers() http.Header {
+	return s.r.Header
+}
+
+func (s httpSource) Query(name string) string {
+	return s.r.URL.Query().Get(name)
+}
+
+func (s httpSource) Cookie(name string) string {
+	cookie, err := s.r.Cookie(name)
+	if err != nil {
+		return ""
+	}
+	return cookie.Value
+}
+
+func (s httpSource) RemoteAddr() string {
+	return s.r.RemoteAddr
+}
+
+// ClientIP só acredita nos headers de proxies confiáveis, pela estratégia de SetClientIPResolver
+func (s httpSource) ClientIP() string {
+	return ClientIP(s)
+}
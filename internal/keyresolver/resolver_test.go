@@ -0,0 +1,109 @@
+package keyresolver_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/Higor-ViniciusDev/posgo_raterlimite/internal/keyresolver"
+)
+
+func TestClientIP_DefaultIgnoresHeaders(t *testing.T) {
+	cases := []struct {
+		name    string
+		headers map[string]string
+		remote  string
+		ip      string
+	}{
+		// antes qualquer cliente escolhia o IP mandando esses headers
+		{"true client ip", map[string]string{"True-Client-IP": "203.0.113.1"}, "10.0.0.1:80", "10.0.0.1"},
+		{"forwarded for", map[string]string{"X-Forwarded-For": "203.0.113.3"}, "10.0.0.1:80", "10.0.0.1"},
+		{"remote addr", nil, "10.0.0.1:80", "10.0.0.1"},
+		{"ipv6", nil, "[2001:db8::1]:443", "2001:db8::1"},
+		{"invalid", nil, "@", ""},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if got := keyresolver.ClientIP(keyresolver.FromHTTP(request(c.remote, c.headers))); got != c.ip {
+				t.Fatalf("esperado %q, recebido %q", c.ip, got)
+			}
+		})
+	}
+}
+
+func TestClientIPResolver_Strategies(t *testing.T) {
+	cases := []struct {
+		name    string
+		config  keyresolver.ClientIPConfig
+		headers map[string]string
+		remote  string
+		ip      string
+	}{
+		{
+			"trusted proxies pula os saltos confiáveis",
+			keyresolver.ClientIPConfig{Strategy: keyresolver.ClientIPTrustedProxies, TrustedProxies: []string{"10.0.0.0/8", "192.0.2.10"}},
+			map[string]string{"X-Forwarded-For": "198.51.100.66, 203.0.113.3, 192.0.2.10"}, "10.0.0.1:80", "203.0.113.3",
+		},
+		{
+			"trusted proxies ignora o header de conexão direta",
+			keyresolver.ClientIPConfig{Strategy: keyresolver.ClientIPTrustedProxies, TrustedProxies: []string{"10.0.0.0/8"}},
+			map[string]string{"X-Forwarded-For": "203.0.113.3"}, "198.51.100.7:80", "198.51.100.7",
+		},
+		{
+			"rightmost non private",
+			keyresolver.ClientIPConfig{Strategy: keyresolver.ClientIPRightmostNonPrivate},
+			map[string]string{"X-Forwarded-For": "8.8.4.4, 1.1.1.1, 10.0.0.9"}, "10.0.0.1:80", "1.1.1.1",
+		},
+		{
+			"header único",
+			keyresolver.ClientIPConfig{Strategy: keyresolver.ClientIPHeader, Header: "X-Real-IP"},
+			map[string]string{"X-Real-IP": "203.0.113.2"}, "10.0.0.1:80", "203.0.113.2",
+		},
+		{
+			"header único de proxy não confiável",
+			keyresolver.ClientIPConfig{Strategy: keyresolver.ClientIPHeader, Header: "X-Real-IP", TrustedProxies: []string{"10.0.0.0/8"}},
+			map[string]string{"X-Real-IP": "203.0.113.2"}, "198.51.100.7:80", "198.51.100.7",
+		},
+		{
+			"header inválido fica com a conexão",
+			keyresolver.ClientIPConfig{Strategy: keyresolver.ClientIPHeader, Header: "X-Real-IP"},
+			map[string]string{"X-Real-IP": "not-an-ip"}, "10.0.0.1:80", "10.0.0.1",
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			resolver, err := keyresolver.NewClientIPResolver(c.config)
+			if err != nil {
+				t.Fatalf("configuração válida recusada: %v", err)
+			}
+			if got := resolver.ClientIP(keyresolver.FromHTTP(request(c.remote, c.headers))); got != c.ip {
+				t.Fatalf("esperado %q, recebido %q", c.ip, got)
+			}
+		})
+	}
+}
+
+func TestNewClientIPResolver_RejectsInvalidConfig(t *testing.T) {
+	for _, config := range []keyresolver.ClientIPConfig{
+		{Strategy: "leftmost"},
+		{Strategy: keyresolver.ClientIPTrustedProxies},
+		{Strategy: keyresolver.ClientIPTrustedProxies, TrustedProxies: []string{"10.0.0.0/33"}},
+		{Strategy: keyresolver.ClientIPHeader},
+		{Strategy: keyresolver.ClientIPHeader, Header: "X-Forwarded-For"},
+	} {
+		if _, err := keyresolver.NewClientIPResolver(config); err == nil {
+			t.Fatalf("configuração inválida aceita: %+v", config)
+		}
+	}
+}
+
+func request(remote string, headers map[string]string) *http.Request {
+	req := httptest.NewRequest("GET", "/", nil)
+	req.RemoteAddr = remote
+	for name, value := range headers {
+		req.Header.Set(name, value)
+	}
+	return req
+}
@@ -0,0 +1,45 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/Higor-ViniciusDev/posgo_raterlimite/internal/entity/access_list_entity"
+	"github.com/Higor-ViniciusDev/posgo_raterlimite/internal/internal_error"
+	"github.com/Higor-ViniciusDev/posgo_raterlimite/internal/keyresolver"
+	"github.com/Higor-ViniciusDev/posgo_raterlimite/internal/usecase/access_list_usecase"
+)
+
+// WithAccessList consulta as listas de IP antes de resolver a strategy: IP na allow passa sem rate limit
+// e IP na deny recebe 403. Basta em um middleware da cadeia: os seguintes respeitam a allow pelo contexto.
+func WithAccessList(accessList access_list_usecase.AccessListUsecaseInterface) RateLimiterOption {
+	return func(o *rateLimiterOptions) {
+		o.accessList = accessList
+	}
+}
+
+// allowListedKey marca no contexto a requisição que um middleware anterior liberou pela allow
+type allowListedKey struct{}
+
+// checkAccessList devolve true quando a lista já respondeu a requisição (encaminhada ou recusada).
+// A lista é consultada uma vez por requisição: o IP liberado segue com a marca no contexto.
+func checkAccessList(w http.ResponseWriter, r *http.Request, next http.Handler, o rateLimiterOptions) bool {
+	if allowed, _ := r.Context().Value(allowListedKey{}).(bool); allowed {
+		next.ServeHTTP(w, r)
+		return true
+	}
+	if o.accessList == nil {
+		return false
+	}
+
+	switch o.accessList.Check(keyresolver.ClientIP(keyresolver.FromHTTP(r))) {
+	case access_list_entity.Denied:
+		writeError(w, r, internal_error.NewForbiddenError("ip address is denied"), o)
+		return true
+	case access_list_entity.Allowed:
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), allowListedKey{}, true)))
+		return true
+	default:
+		return false
+	}
+}
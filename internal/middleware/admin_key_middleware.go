@@ -0,0 +1,34 @@
+package middleware
+
+import (
+	"crypto/subtle"
+	"net/http"
+
+	"github.com/Higor-ViniciusDev/posgo_raterlimite/internal/internal_error"
+)
+
+// AdminKeyMiddleware protege as rotas de administração: só passa quem enviar o header ADMIN-KEY igual à chave
+func AdminKeyMiddleware(adminKey string) func(http.Handler) http.Handler {
+	return requireKey("ADMIN-KEY", adminKey, "admin key invalid")
+}
+
+// ServiceKeyMiddleware protege as rotas de consulta usadas por outros serviços (/v1/ratelimit/*):
+// só passa quem enviar o header SERVICE-KEY igual à chave
+func ServiceKeyMiddleware(serviceKey string) func(http.Handler) http.Handler {
+	return requireKey("SERVICE-KEY", serviceKey, "service key invalid")
+}
+
+// requireKey compara o header com a chave em tempo constante, chave vazia recusa todos
+func requireKey(header string, expected string, message string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			key := r.Header.Get(header)
+			if expected == "" || subtle.ConstantTimeCompare([]byte(key), []byte(expected)) != 1 {
+				writeError(w, r, internal_error.NewForbiddenError(message), rateLimiterOptions{})
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
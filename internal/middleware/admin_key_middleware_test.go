@@ -0,0 +1,40 @@
+package middleware_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/Higor-ViniciusDev/posgo_raterlimite/internal/middleware"
+)
+
+func Test_ServiceKeyMiddleware(t *testing.T) {
+	handler := middleware.ServiceKeyMiddleware("chave-servico")(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	cases := []struct {
+		name   string
+		header string
+		value  string
+		code   int
+	}{
+		{"sem chave", "", "", http.StatusForbidden},
+		{"chave errada", "SERVICE-KEY", "outra", http.StatusForbidden},
+		{"chave de admin no lugar", "ADMIN-KEY", "chave-servico", http.StatusForbidden},
+		{"chave certa", "SERVICE-KEY", "chave-servico", http.StatusOK},
+	}
+
+	for _, c := range cases {
+		req := httptest.NewRequest(http.MethodPost, "/v1/ratelimit/check", nil)
+		if c.header != "" {
+			req.Header.Set(c.header, c.value)
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+
+		if rec.Code != c.code {
+			t.Fatalf("%s: esperado %d, recebido %d", c.name, c.code, rec.Code)
+		}
+	}
+}
@@ -0,0 +1,48 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/Higor-ViniciusDev/posgo_raterlimite/internal/keyresolver"
+	"github.com/Higor-ViniciusDev/posgo_raterlimite/internal/ratelimiter"
+	"github.com/Higor-ViniciusDev/posgo_raterlimite/internal/usecase/policy_usecase"
+)
+
+// ConcurrencyLimiterMiddleware limita as requisições em andamento por key, com a mesma cadeia de
+// strategies do RateLimiterMiddleware: cada key da cadeia reserva a própria vaga antes do next.ServeHTTP
+// e devolve ao final. Sem vaga em qualquer uma a resposta é o mesmo 429 do rate limit.
+func ConcurrencyLimiterMiddleware(policy *policy_usecase.PolicyUsecase, limiter *ratelimiter.ConcurrencyLimiter, opts ...RateLimiterOption) func(http.Handler) http.Handler {
+	o := newRateLimiterOptions(opts)
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if checkAccessList(w, r, next, o) {
+				return
+			}
+
+			snapshot := policy.Snapshot()
+
+			resolved, err := keyresolver.ResolveAll(r.Context(), snapshot, keyresolver.FromHTTP(r), snapshot.MatchTolkenFallback(r.Method, r.URL.Path))
+			if err != nil {
+				writeError(w, r, err, o)
+				return
+			}
+
+			for _, item := range resolved {
+				max := item.Strategy.GetMaxInFlight()
+				if max <= 0 {
+					continue
+				}
+				// a key sem vaga recusa a requisição e devolve as vagas já reservadas pelo defer
+				if !limiter.Acquire(item.Key, max) {
+					writeLimitExceeded(w, r, 0, item.Strategy.GetInfoType(), o)
+					return
+				}
+				// libera mesmo se o handler entrar em panic
+				defer limiter.Release(item.Key)
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -0,0 +1,197 @@
+package middleware_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/Higor-ViniciusDev/posgo_raterlimite/internal/entity/access_list_entity"
+	"github.com/Higor-ViniciusDev/posgo_raterlimite/internal/entity/limit_entity"
+	"github.com/Higor-ViniciusDev/posgo_raterlimite/internal/entity/tolken_entity"
+	"github.com/Higor-ViniciusDev/posgo_raterlimite/internal/infra/repository"
+	"github.com/Higor-ViniciusDev/posgo_raterlimite/internal/internal_error"
+	"github.com/Higor-ViniciusDev/posgo_raterlimite/internal/middleware"
+	"github.com/Higor-ViniciusDev/posgo_raterlimite/internal/ratelimiter"
+	"github.com/Higor-ViniciusDev/posgo_raterlimite/internal/usecase/access_list_usecase"
+	"github.com/Higor-ViniciusDev/posgo_raterlimite/internal/usecase/policy_usecase"
+	"github.com/Higor-ViniciusDev/posgo_raterlimite/internal/usecase/strategy_usecase"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+func Test_ConcurrencyLimiterMiddleware_IP(t *testing.T) {
+	t.Setenv("MAX_IN_FLIGHT_IP", "2")
+
+	policy := newTestPolicy(t)
+
+	limiter := ratelimiter.NewConcurrencyLimiter(0)
+
+	// handler lento: segura a requisição até o teste liberar
+	entered := make(chan struct{})
+	release := make(chan struct{})
+	slowHandler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		entered <- struct{}{}
+		<-release
+		w.WriteHeader(http.StatusOK)
+	})
+
+	handler := middleware.ConcurrencyLimiterMiddleware(policy, limiter)(slowHandler)
+
+	serve := func() int {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.RemoteAddr = "10.0.0.2:1234"
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 2; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if code := serve(); code != http.StatusOK {
+				t.Errorf("requisição em andamento deveria terminar com 200, recebido %d", code)
+			}
+		}()
+		<-entered
+	}
+
+	// as duas vagas estão ocupadas
+	if code := serve(); code != http.StatusTooManyRequests {
+		t.Fatalf("terceira requisição simultânea deveria receber 429, recebido %d", code)
+	}
+
+	close(release)
+	wg.Wait()
+
+	if inFlight := limiter.InFlight("ip:10.0.0.2"); inFlight != 0 {
+		t.Fatalf("as vagas deveriam ser devolvidas ao fim das requisições, em andamento %d", inFlight)
+	}
+
+	go func() { <-entered }()
+	if code := serve(); code != http.StatusOK {
+		t.Fatalf("com as vagas livres a requisição deveria passar, recebido %d", code)
+	}
+}
+
+// allowAllStub libera todo IP e conta as consultas
+type allowAllStub struct {
+	checks atomic.Int64
+}
+
+func (s *allowAllStub) Check(ip string) access_list_entity.Action {
+	s.checks.Add(1)
+	return access_list_entity.Allowed
+}
+
+func (s *allowAllStub) Add(ctx context.Context, input access_list_usecase.EntryInputDTO) *internal_error.InternalError {
+	return nil
+}
+
+func (s *allowAllStub) Remove(ctx context.Context, input access_list_usecase.EntryInputDTO) *internal_error.InternalError {
+	return nil
+}
+
+func (s *allowAllStub) List(ctx context.Context) (*access_list_usecase.AccessListOutputDTO, *internal_error.InternalError) {
+	return &access_list_usecase.AccessListOutputDTO{}, nil
+}
+
+func Test_ConcurrencyLimiterMiddleware_AllowListedOnce(t *testing.T) {
+	t.Setenv("MAX_IN_FLIGHT_IP", "1")
+
+	policy := newTestPolicy(t)
+	limiter := ratelimiter.NewConcurrencyLimiter(0)
+	accessList := &allowAllStub{}
+
+	var served atomic.Int64
+	finalHandler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		served.Add(1)
+		w.WriteHeader(http.StatusOK)
+	})
+
+	// mesma ordem do main: a lista só no primeiro middleware
+	handler := middleware.RateLimiterMiddleware(policy, ratelimiter.NewLocalLimiter(0), middleware.WithAccessList(accessList))(
+		middleware.ConcurrencyLimiterMiddleware(policy, limiter)(finalHandler),
+	)
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.RemoteAddr = "10.0.0.3:1234"
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusOK || served.Load() != 1 {
+		t.Fatalf("IP liberado deveria chegar ao handler, recebido %d", rec.Code)
+	}
+	if checks := accessList.checks.Load(); checks != 1 {
+		t.Fatalf("a lista deveria ser consultada uma vez por requisição, consultada %d", checks)
+	}
+	if reserved := limiter.InFlight("ip:10.0.0.3"); reserved != 0 {
+		t.Fatalf("IP liberado não deveria reservar vaga, em andamento %d", reserved)
+	}
+}
+
+func Test_ConcurrencyLimiterMiddleware_PolicyChain(t *testing.T) {
+	mr, err := miniredis.Run()
+	if err != nil {
+		t.Fatalf("erro ao iniciar miniredis: %v", err)
+	}
+	t.Cleanup(mr.Close)
+
+	redisClient := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	tolkenRepository := repository.NewTolkenDB(redisClient)
+	requestInfoRepository := repository.NewRequestInfoRepository(redisClient)
+	for _, tolken := range []string{"tolken-a", "tolken-b"} {
+		saveTolken(t, tolkenRepository, &tolken_entity.Tolken{TolkenString: tolken})
+	}
+
+	// cada tolken aceita 5 em andamento, mas o IP só 1
+	policy := policy_usecase.NewPolicyUsecase(
+		strategy_usecase.NewIPStrategyUsecaseWithLimits(limit_entity.StrategyLimits{Limit: 100, TTL: 10 * time.Second, MaxInFlight: 1}, requestInfoRepository),
+		strategy_usecase.NewTokenStrategyUsecaseWithLimits(limit_entity.StrategyLimits{Limit: 100, TTL: 10 * time.Second, MaxInFlight: 5}, tolkenRepository, requestInfoRepository),
+	)
+	policy.Chain = limit_entity.Chain{limit_entity.ChainTolken, limit_entity.ChainIP}
+	limiter := ratelimiter.NewConcurrencyLimiter(0)
+
+	entered := make(chan struct{})
+	release := make(chan struct{})
+	slowHandler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		entered <- struct{}{}
+		<-release
+		w.WriteHeader(http.StatusOK)
+	})
+	handler := middleware.ConcurrencyLimiterMiddleware(policy, limiter)(slowHandler)
+
+	serve := func(tolken string) int {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.RemoteAddr = "10.0.0.4:1234"
+		req.Header.Set("API-KEY", tolken)
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	done := make(chan int)
+	go func() { done <- serve("tolken-a") }()
+	<-entered
+
+	// outro tolken do mesmo IP esbarra na vaga do IP e não fica com a vaga do próprio tolken
+	if code := serve("tolken-b"); code != http.StatusTooManyRequests {
+		t.Fatalf("o IP sem vaga deveria recusar a cadeia, recebido %d", code)
+	}
+	if inFlight := limiter.InFlight("token:tolken-b"); inFlight != 0 {
+		t.Fatalf("a vaga do tolken deveria ser devolvida na recusa, em andamento %d", inFlight)
+	}
+
+	close(release)
+	if code := <-done; code != http.StatusOK {
+		t.Fatalf("a requisição em andamento deveria terminar com 200, recebido %d", code)
+	}
+	if limiter.InFlight("token:tolken-a") != 0 || limiter.InFlight("ip:10.0.0.4") != 0 {
+		t.Fatal("as vagas da cadeia deveriam ser devolvidas ao fim da requisição")
+	}
+}
@@ -0,0 +1,67 @@
+package middleware
+
+import (
+	"encoding/json"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/Higor-ViniciusDev/posgo_raterlimite/configuration/rest_err"
+	"github.com/Higor-ViniciusDev/posgo_raterlimite/internal/internal_error"
+	"github.com/Higor-ViniciusDev/posgo_raterlimite/internal/ratelimiter"
+)
+
+// Desafio do 401 de tolken inválido ou expirado, com o erro invalid_token do RFC 6750
+const tolkenChallenge = `Bearer realm="ratelimiter", error="invalid_token"`
+
+// WithProblemJSON responde os erros no formato application/problem+json (RFC 7807) em vez do RestErr
+func WithProblemJSON() RateLimiterOption {
+	return func(o *rateLimiterOptions) {
+		o.problemJSON = true
+	}
+}
+
+// writeLimitExceeded responde o 429 do limiter, com Retry-After em segundos quando a espera é conhecida
+func writeLimitExceeded(w http.ResponseWriter, r *http.Request, retryAfter time.Duration, strategy string, o rateLimiterOptions) {
+	var seconds int64
+	if retryAfter > 0 {
+		seconds = int64(math.Ceil(retryAfter.Seconds()))
+		w.Header().Set("Retry-After", strconv.FormatInt(seconds, 10))
+	}
+
+	restError := rest_err.ConvertInternalErrorToRestError(ratelimiter.NewLimitExceededError())
+	if !o.problemJSON {
+		writeJSON(w, "application/json", restError.Code, restError)
+		return
+	}
+
+	problem := rest_err.NewProblemDetails(restError, r.URL.Path)
+	problem.Strategy = strategy
+	problem.RetryAfter = seconds
+	writeJSON(w, rest_err.ProblemContentType, problem.Status, problem)
+}
+
+// writeError responde o erro como RestErr em JSON, o mesmo formato dos controllers.
+// O 401 leva o WWW-Authenticate pedindo um tolken válido.
+func writeError(w http.ResponseWriter, r *http.Request, err *internal_error.InternalError, o rateLimiterOptions) {
+	if err.Err == "unauthorized" {
+		w.Header().Set("WWW-Authenticate", tolkenChallenge)
+	}
+
+	restError := rest_err.ConvertInternalErrorToRestError(err)
+	if !o.problemJSON {
+		writeJSON(w, "application/json", restError.Code, restError)
+		return
+	}
+
+	problem := rest_err.NewProblemDetails(restError, r.URL.Path)
+	writeJSON(w, rest_err.ProblemContentType, problem.Status, problem)
+}
+
+func writeJSON(w http.ResponseWriter, contentType string, code int, body any) {
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(body)
+}
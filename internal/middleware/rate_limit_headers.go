@@ -0,0 +1,85 @@
+package middleware
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Higor-ViniciusDev/posgo_raterlimite/internal/entity/limit_entity"
+	"github.com/Higor-ViniciusDev/posgo_raterlimite/internal/ratelimiter"
+	"github.com/Higor-ViniciusDev/posgo_raterlimite/internal/usecase/access_list_usecase"
+)
+
+// RateLimiterOption personaliza o RateLimiterMiddleware
+type RateLimiterOption func(*rateLimiterOptions)
+
+type rateLimiterOptions struct {
+	ietfHeaders bool
+	problemJSON bool
+	routes      limit_entity.RoutePolicies
+	accessList  access_list_usecase.AccessListUsecaseInterface
+}
+
+// WithIETFHeaders também envia os campos RateLimit e RateLimit-Policy do draft da IETF
+// (draft-ietf-httpapi-ratelimit-headers), além dos X-RateLimit-*.
+func WithIETFHeaders() RateLimiterOption {
+	return func(o *rateLimiterOptions) {
+		o.ietfHeaders = true
+	}
+}
+
+// WithRoutePolicies troca as regras da strategy nas rotas que casarem (método e caminho da requisição),
+// com os contadores separados por rota.
+func WithRoutePolicies(routes limit_entity.RoutePolicies) RateLimiterOption {
+	return func(o *rateLimiterOptions) {
+		o.routes = append(o.routes, routes...)
+	}
+}
+
+func newRateLimiterOptions(opts []RateLimiterOption) rateLimiterOptions {
+	var o rateLimiterOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
+
+// setRateLimitHeaders descreve a cota da key na resposta: X-RateLimit-Reset é o horário unix em que
+// a cota volta a ficar cheia e os campos da IETF usam segundos a partir de agora.
+func setRateLimitHeaders(w http.ResponseWriter, decision ratelimiter.Decision, rules ratelimiter.Rules, policyName string, o rateLimiterOptions) {
+	header := w.Header()
+	reset := secondsUntil(decision.ResetAt)
+
+	header.Set("X-RateLimit-Limit", strconv.FormatInt(decision.Limit, 10))
+	header.Set("X-RateLimit-Remaining", strconv.FormatInt(decision.Remaining, 10))
+	header.Set("X-RateLimit-Reset", strconv.FormatInt(time.Now().Unix()+reset, 10))
+
+	if !o.ietfHeaders {
+		return
+	}
+
+	policyName = strings.ToLower(policyName)
+	// a janela da decisão, com várias janelas é a que bloqueou ou a mais perto de esgotar
+	ttl := rules.TTL
+	if decision.Window > 0 {
+		ttl = decision.Window
+	}
+	window := int64(math.Ceil(ttl.Seconds()))
+	header.Set("RateLimit-Policy", fmt.Sprintf("%q;q=%d;w=%d", policyName, decision.Limit, window))
+	header.Set("RateLimit", fmt.Sprintf("%q;r=%d;t=%d", policyName, decision.Remaining, reset))
+}
+
+// secondsUntil arredonda para cima, o cliente nunca deve voltar antes da hora
+func secondsUntil(at time.Time) int64 {
+	if at.IsZero() {
+		return 0
+	}
+	wait := time.Until(at)
+	if wait <= 0 {
+		return 0
+	}
+	return int64(math.Ceil(wait.Seconds()))
+}
@@ -1,93 +1,78 @@
 package middleware
 
 import (
-	"net"
+	"context"
 	"net/http"
-	"strings"
 
-	"github.com/Higor-ViniciusDev/posgo_raterlimite/configuration/rest_err"
-	"github.com/Higor-ViniciusDev/posgo_raterlimite/internal/internal_error"
+	"github.com/Higor-ViniciusDev/posgo_raterlimite/configuration/logger"
+	"github.com/Higor-ViniciusDev/posgo_raterlimite/internal/keyresolver"
 	"github.com/Higor-ViniciusDev/posgo_raterlimite/internal/ratelimiter"
 	"github.com/Higor-ViniciusDev/posgo_raterlimite/internal/usecase/policy_usecase"
 )
 
-// RateLimiterMiddleware recebe PolicyUsecase e RateLimiterInstance.
-// O Order é: PolicyUsecase resolve strategy -> strategy gera key+rules -> envia msg ao RateLimiter.
-func RateLimiterMiddleware(policy *policy_usecase.PolicyUsecase, rl *ratelimiter.RateLimiter) func(http.Handler) http.Handler {
+// RateLimiterMiddleware recebe PolicyUsecase e o Limiter (pool de workers, direto ou redis).
+// O Order é: PolicyUsecase resolve strategy -> strategy gera key+rules -> Limiter decide.
+// Com a cadeia da política (ex: tolken e ip) todas as strategies são decididas juntas pelo AllowAll.
+// Toda resposta decidida pelo limiter leva os headers X-RateLimit-* (e Retry-After quando bloqueada).
+func RateLimiterMiddleware(policy *policy_usecase.PolicyUsecase, limiter ratelimiter.Limiter, opts ...RateLimiterOption) func(http.Handler) http.Handler {
+	o := newRateLimiterOptions(opts)
+
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			var trueClientIP = http.CanonicalHeaderKey("True-Client-IP")
-			var xForwardedFor = http.CanonicalHeaderKey("X-Forwarded-For")
-			var xRealIP = http.CanonicalHeaderKey("X-Real-IP")
-
-			// monta input
-			var input policy_usecase.InputPolicyDTO
-			if api := r.Header.Get("API-KEY"); api != "" {
-				input.Tolken = api
+			if checkAccessList(w, r, next, o) {
+				return
 			}
 
-			//Exemplo tirado do middleware https://github.com/go-chi/chi/blob/master/middleware/realip.go
-			var ip string
-
-			if tcip := r.Header.Get(trueClientIP); tcip != "" {
-				ip = tcip
-			} else if xrip := r.Header.Get(xRealIP); xrip != "" {
-				ip = xrip
-			} else if xff := r.Header.Get(xForwardedFor); xff != "" {
-				ip, _, _ = strings.Cut(xff, ",")
-			} else if host := r.RemoteAddr; host != "" {
-				ip = host
-			}
+			// a mesma política do começo ao fim da requisição, mesmo com um reload no meio
+			snapshot := policy.Snapshot()
 
-			if strings.Contains(ip, ":") {
-				host, _, err := net.SplitHostPort(ip)
-				if err == nil {
-					ip = host
-				}
+			resolved, err := keyresolver.ResolveAll(r.Context(), snapshot, keyresolver.FromHTTP(r), snapshot.MatchTolkenFallback(r.Method, r.URL.Path))
+			if err != nil {
+				writeError(w, r, err, o)
+				return
 			}
 
-			if ip == "" || net.ParseIP(ip) == nil {
-				input.IP = ""
-			} else {
-				input.IP = net.ParseIP(ip).String()
-			}
-			// resolve strategy
-			strategy, key := policy.Resolver(input)
+			checks := newChecks(r, snapshot, resolved, o)
 
-			// generate key (token strategy may validate token)
-			key, err := strategy.GenerateKey(r.Context(), key)
+			decision, index, err := limiter.AllowAll(r.Context(), checks)
 			if err != nil {
-				restError := rest_err.ConvertInternalErrorToRestError(err)
-				http.Error(w, restError.Message, restError.Code)
+				writeError(w, r, err, o)
 				return
 			}
 
-			reply := make(chan *internal_error.InternalError, 1)
-			msg := ratelimiter.RateLimitMessage{
-				Ctx:       r.Context(),
-				Key:       key,
-				Limit:     strategy.GetLimit(),
-				TTL:       strategy.GetTTL(),
-				ReplyChan: reply,
-				Strategy:  strategy,
-			}
+			// os headers descrevem a strategy que decidiu: a que recusou ou a mais perto de esgotar
+			strategy := resolved[index].Strategy
+			setRateLimitHeaders(w, decision, checks[index].Rules, strategy.GetInfoType(), o)
 
-			// send to rate limiter
-			select {
-			case rl.InputChan <- msg:
-				// wait reply
-				if err := <-reply; err != nil {
-					restError := rest_err.ConvertInternalErrorToRestError(err)
-					http.Error(w, restError.Message, restError.Code)
-					return
-				}
-			case <-r.Context().Done():
-				http.Error(w, "request canceled", http.StatusRequestTimeout)
+			if !decision.Allowed {
+				writeLimitExceeded(w, r, decision.RetryAfter(), strategy.GetInfoType(), o)
 				return
 			}
 
+			//Para fins de info, apenas salvar os que deram sucesso é requisito do projeto
+			for i, item := range resolved {
+				go saveRequestInfo(item.Strategy, checks[i].Key)
+			}
+
 			// passed rate limiter -> forward
 			next.ServeHTTP(w, r)
 		})
 	}
 }
+
+// newChecks monta a key e as regras de cada strategy resolvida, com a rota aplicada em todas
+func newChecks(r *http.Request, policy *policy_usecase.Snapshot, resolved []policy_usecase.ResolvedStrategy, o rateLimiterOptions) []ratelimiter.Check {
+	// as rotas das options valem antes das rotas da política
+	route, ok := o.routes.Match(r.Method, r.URL.Path)
+	if !ok {
+		route, ok = policy.MatchRoute(r.Method, r.URL.Path)
+	}
+
+	return ratelimiter.NewChecks(r, resolved, route, ok)
+}
+
+func saveRequestInfo(strategy policy_usecase.RateLimitStrategy, key string) {
+	if err := strategy.SaveRequestInfo(context.Background(), key); err != nil {
+		logger.Error("erro ao salvar request info", err)
+	}
+}
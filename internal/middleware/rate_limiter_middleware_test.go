@@ -1,19 +1,28 @@
 package middleware_test
 
 import (
+	"context"
+	"encoding/json"
 	"net/http"
 	"net/http/httptest"
 	"os"
+	"strconv"
 	"sync"
 	"testing"
 	"time"
 
+	"github.com/Higor-ViniciusDev/posgo_raterlimite/configuration/policy"
+	"github.com/Higor-ViniciusDev/posgo_raterlimite/configuration/rest_err"
+	"github.com/Higor-ViniciusDev/posgo_raterlimite/internal/entity/key_entity"
+	"github.com/Higor-ViniciusDev/posgo_raterlimite/internal/entity/limit_entity"
+	"github.com/Higor-ViniciusDev/posgo_raterlimite/internal/entity/tolken_entity"
 	"github.com/Higor-ViniciusDev/posgo_raterlimite/internal/infra/repository"
 	"github.com/Higor-ViniciusDev/posgo_raterlimite/internal/middleware"
 	"github.com/Higor-ViniciusDev/posgo_raterlimite/internal/ratelimiter"
+	"github.com/Higor-ViniciusDev/posgo_raterlimite/internal/usecase/access_list_usecase"
 	"github.com/Higor-ViniciusDev/posgo_raterlimite/internal/usecase/policy_usecase"
 	"github.com/Higor-ViniciusDev/posgo_raterlimite/internal/usecase/strategy_usecase"
-	"github.com/alicebob/miniredis"
+	"github.com/alicebob/miniredis/v2"
 	"github.com/redis/go-redis/v9"
 )
 
@@ -28,8 +37,8 @@ func Test_RateLimiterMiddleware_IP(t *testing.T) {
 	requestInfoRepository := repository.NewRequestInfoRepository(redisClient)
 
 	// mock usecases
-	ipStr := strategy_usecase.NewIPStrategyUsecase(requestInfoRepository)
-	tokStr := strategy_usecase.NewTokenStrategyUsecase(requestTolkenRepository, requestInfoRepository)
+	ipStr := strategy_usecase.NewIPStrategyUsecaseWithLimits(envLimits(t, policy.EnvIP), requestInfoRepository)
+	tokStr := strategy_usecase.NewTokenStrategyUsecaseWithLimits(envLimits(t, policy.EnvTolken), requestTolkenRepository, requestInfoRepository)
 
 	// PolicyUsecase
 	policy := &policy_usecase.PolicyUsecase{
@@ -118,3 +127,490 @@ func Test_RateLimiterMiddleware_IP(t *testing.T) {
 		t.Logf("AVISO: Nem todas as requisições foram contabilizadas (sucesso+bloqueadas: %d, total: %d)", success+blocked, numRequests)
 	}
 }
+
+func Test_RateLimiterMiddleware_Headers(t *testing.T) {
+	// a janela fixa recusa a requisição que atinge o limite: passam 2
+	t.Setenv("REQUEST_PER_SECOND_IP", "3")
+	t.Setenv("TLL_KEY_IP", "10")
+	t.Setenv("TIME_UNLOCKED_NEW_REQUEST_IP", "3")
+
+	policy := newTestPolicy(t)
+
+	finalHandler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	handler := middleware.RateLimiterMiddleware(policy, ratelimiter.NewLocalLimiter(0), middleware.WithIETFHeaders())(finalHandler)
+
+	serve := func() *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.RemoteAddr = "10.0.0.3:1234"
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	for remaining := 1; remaining >= 0; remaining-- {
+		rec := serve()
+		if rec.Code != http.StatusOK {
+			t.Fatalf("requisição dentro do limite deveria passar, recebido %d", rec.Code)
+		}
+		if got := rec.Header().Get("X-RateLimit-Limit"); got != "3" {
+			t.Fatalf("X-RateLimit-Limit esperado 3, recebido %q", got)
+		}
+		if got := rec.Header().Get("X-RateLimit-Remaining"); got != strconv.Itoa(remaining) {
+			t.Fatalf("X-RateLimit-Remaining esperado %d, recebido %q", remaining, got)
+		}
+		reset, _ := strconv.ParseInt(rec.Header().Get("X-RateLimit-Reset"), 10, 64)
+		if wait := reset - time.Now().Unix(); wait < 9 || wait > 11 {
+			t.Fatalf("X-RateLimit-Reset deveria ser o fim da janela, faltam %ds", wait)
+		}
+		if got := rec.Header().Get("RateLimit-Policy"); got != `"ip";q=3;w=10` {
+			t.Fatalf("RateLimit-Policy inesperado: %q", got)
+		}
+	}
+
+	rec := serve()
+	if rec.Code != http.StatusTooManyRequests {
+		t.Fatalf("requisição acima do limite deveria receber 429, recebido %d", rec.Code)
+	}
+	if got := rec.Header().Get("X-RateLimit-Remaining"); got != "0" {
+		t.Fatalf("bloqueada deveria ter X-RateLimit-Remaining 0, recebido %q", got)
+	}
+	if got := rec.Header().Get("Retry-After"); got != "10" {
+		t.Fatalf("Retry-After deveria apontar para o fim da janela, recebido %q", got)
+	}
+	if got := rec.Header().Get("RateLimit"); got != `"ip";r=0;t=10` {
+		t.Fatalf("RateLimit inesperado: %q", got)
+	}
+}
+
+// envLimits lê os limites da strategy do env atual, como a inicialização do serviço
+func envLimits(t *testing.T, suffix string) limit_entity.StrategyLimits {
+	t.Helper()
+
+	strategy, err := policy.StrategyFromEnv(suffix)
+	if err != nil {
+		t.Fatalf("variaveis invalidas para strategy %s: %v", suffix, err)
+	}
+	return strategy.Limits()
+}
+
+// saveTolken grava o tolken pelo repositório, como o POST /tolken
+func saveTolken(t *testing.T, repo *repository.TolkenRepository, tolken *tolken_entity.Tolken) {
+	t.Helper()
+
+	if err := repo.Save(context.Background(), tolken); err != nil {
+		t.Fatalf("erro ao salvar tolken %s: %v", tolken.TolkenString, err)
+	}
+}
+
+// newTestPolicy monta a policy com as strategies lendo o env atual e os repositórios em um miniredis
+func newTestPolicy(t *testing.T) *policy_usecase.PolicyUsecase {
+	t.Helper()
+
+	mr, err := miniredis.Run()
+	if err != nil {
+		t.Fatalf("erro ao iniciar miniredis: %v", err)
+	}
+	t.Cleanup(mr.Close)
+
+	redisClient := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	requestInfoRepository := repository.NewRequestInfoRepository(redisClient)
+
+	return &policy_usecase.PolicyUsecase{
+		TokenStrategy: strategy_usecase.NewTokenStrategyUsecaseWithLimits(envLimits(t, policy.EnvTolken), repository.NewTolkenDB(redisClient), requestInfoRepository),
+		IPStrategy:    strategy_usecase.NewIPStrategyUsecaseWithLimits(envLimits(t, policy.EnvIP), requestInfoRepository),
+	}
+}
+
+func Test_RateLimiterMiddleware_ErrorBody(t *testing.T) {
+	t.Setenv("REQUEST_PER_SECOND_IP", "1")
+	t.Setenv("TLL_KEY_IP", "10")
+	t.Setenv("TIME_UNLOCKED_NEW_REQUEST_IP", "0")
+
+	policy := newTestPolicy(t)
+	finalHandler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+
+	exceed := func(handler http.Handler) *httptest.ResponseRecorder {
+		var rec *httptest.ResponseRecorder
+		for i := 0; i < 2; i++ {
+			req := httptest.NewRequest(http.MethodGet, "/export", nil)
+			req.RemoteAddr = "10.0.0.4:1234"
+			rec = httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+		}
+		return rec
+	}
+
+	t.Run("rest_err", func(t *testing.T) {
+		rec := exceed(middleware.RateLimiterMiddleware(policy, ratelimiter.NewLocalLimiter(0))(finalHandler))
+
+		var body rest_err.RestErr
+		if err := json.NewDecoder(rec.Body).Decode(&body); err != nil {
+			t.Fatalf("corpo do 429 deveria ser JSON: %v", err)
+		}
+		if rec.Header().Get("Content-Type") != "application/json" || body.Code != http.StatusTooManyRequests || body.Err != "many_request" {
+			t.Fatalf("RestErr inesperado: %s %+v", rec.Header().Get("Content-Type"), body)
+		}
+	})
+
+	t.Run("problem_json", func(t *testing.T) {
+		rec := exceed(middleware.RateLimiterMiddleware(policy, ratelimiter.NewLocalLimiter(0), middleware.WithProblemJSON())(finalHandler))
+
+		var problem rest_err.ProblemDetails
+		if err := json.NewDecoder(rec.Body).Decode(&problem); err != nil {
+			t.Fatalf("corpo do 429 deveria ser JSON: %v", err)
+		}
+		if rec.Header().Get("Content-Type") != rest_err.ProblemContentType {
+			t.Fatalf("Content-Type esperado %s, recebido %s", rest_err.ProblemContentType, rec.Header().Get("Content-Type"))
+		}
+		if problem.Status != http.StatusTooManyRequests || problem.Title != "Too Many Requests" || problem.Type == "" || problem.Detail == "" {
+			t.Fatalf("problem incompleto: %+v", problem)
+		}
+		if problem.Strategy != "IP" || problem.RetryAfter != 10 || problem.Instance != "/export" {
+			t.Fatalf("problem deveria trazer a strategy e o tempo de espera: %+v", problem)
+		}
+	})
+}
+
+func Test_RateLimiterMiddleware_RoutePolicies(t *testing.T) {
+	// a janela fixa recusa a requisição que atinge o limite, cada limite admite um a menos
+	t.Setenv("REQUEST_PER_SECOND_IP", "4")
+	t.Setenv("TLL_KEY_IP", "10")
+	t.Setenv("TIME_UNLOCKED_NEW_REQUEST_IP", "0")
+
+	routes, err := limit_entity.ParseRoutePolicies("POST /login=2/1m, /search=6/1s")
+	if err != nil {
+		t.Fatalf("erro ao converter políticas: %v", err)
+	}
+
+	finalHandler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	handler := middleware.RateLimiterMiddleware(newTestPolicy(t), ratelimiter.NewLocalLimiter(0), middleware.WithRoutePolicies(routes))(finalHandler)
+
+	serve := func(method, path string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, nil)
+		req.RemoteAddr = "10.0.0.11:1234"
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	if rec := serve(http.MethodPost, "/login"); rec.Code != http.StatusOK || rec.Header().Get("X-RateLimit-Limit") != "2" {
+		t.Fatalf("primeiro login deveria passar com o limite da rota, recebido %d %v", rec.Code, rec.Header())
+	}
+	if rec := serve(http.MethodPost, "/login"); rec.Code != http.StatusTooManyRequests {
+		t.Fatalf("segundo login deveria ser bloqueado pela rota, recebido %d", rec.Code)
+	}
+
+	// /search e as rotas sem política têm contadores próprios
+	for i := 0; i < 5; i++ {
+		if rec := serve(http.MethodGet, "/search/users"); rec.Code != http.StatusOK {
+			t.Fatalf("busca %d dentro do limite da rota deveria passar, recebido %d", i+1, rec.Code)
+		}
+	}
+	for i := 0; i < 3; i++ {
+		if rec := serve(http.MethodGet, "/"); rec.Code != http.StatusOK || rec.Header().Get("X-RateLimit-Limit") != "4" {
+			t.Fatalf("rota sem política deveria usar o limite da strategy, recebido %d %v", rec.Code, rec.Header())
+		}
+	}
+}
+
+func Test_RateLimiterMiddleware_PolicyReloadKeepsCounters(t *testing.T) {
+	// a janela fixa recusa a requisição que atinge o limite: passam 2
+	t.Setenv("REQUEST_PER_SECOND_IP", "3")
+	t.Setenv("TLL_KEY_IP", "10")
+	t.Setenv("TIME_UNLOCKED_NEW_REQUEST_IP", "0")
+
+	policy := newTestPolicy(t)
+	finalHandler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	handler := middleware.RateLimiterMiddleware(policy, ratelimiter.NewLocalLimiter(0))(finalHandler)
+
+	serve := func() *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.RemoteAddr = "10.0.0.12:1234"
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	for i := 0; i < 2; i++ {
+		if rec := serve(); rec.Code != http.StatusOK {
+			t.Fatalf("requisição %d dentro do limite deveria passar, recebido %d", i+1, rec.Code)
+		}
+	}
+
+	ip := policy.IPStrategy.(*strategy_usecase.IPStrategyUsecase)
+	policy.Reload(
+		strategy_usecase.NewIPStrategyUsecaseWithLimits(limit_entity.StrategyLimits{Limit: 4, TTL: 10 * time.Second}, ip.RequestRepository),
+		policy.TokenStrategy,
+		nil,
+		nil,
+		nil,
+	)
+
+	// o contador continua com as 2 requisições anteriores, só cabe mais uma no limite novo
+	if rec := serve(); rec.Code != http.StatusOK || rec.Header().Get("X-RateLimit-Limit") != "4" || rec.Header().Get("X-RateLimit-Remaining") != "0" {
+		t.Fatalf("requisição com o limite novo deveria passar sem zerar o contador, recebido %d %v", rec.Code, rec.Header())
+	}
+	if rec := serve(); rec.Code != http.StatusTooManyRequests {
+		t.Fatalf("o limite novo deveria bloquear, recebido %d", rec.Code)
+	}
+}
+
+func Test_RateLimiterMiddleware_AccessList(t *testing.T) {
+	// a janela fixa recusa a requisição que atinge o limite: passa 1
+	t.Setenv("REQUEST_PER_SECOND_IP", "2")
+	t.Setenv("TLL_KEY_IP", "10")
+	t.Setenv("TIME_UNLOCKED_NEW_REQUEST_IP", "0")
+
+	mr, err := miniredis.Run()
+	if err != nil {
+		t.Fatalf("erro ao iniciar miniredis: %v", err)
+	}
+	t.Cleanup(mr.Close)
+	redisClient := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+
+	// duas réplicas com o mesmo Redis: a alteração feita em uma vale na outra depois do refresh
+	admin := access_list_usecase.NewAccessListUsecase(repository.NewAccessListRepository(redisClient), time.Hour)
+	replica := access_list_usecase.NewAccessListUsecase(repository.NewAccessListRepository(redisClient), time.Millisecond)
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	go replica.Start(ctx)
+
+	for _, input := range []access_list_usecase.EntryInputDTO{
+		{List: "allow", Entry: "10.10.0.0/16"},
+		{List: "deny", Entry: "2001:db8::/32"},
+		{List: "deny", Entry: "10.10.0.66"},
+	} {
+		if err := admin.Add(context.Background(), input); err != nil {
+			t.Fatalf("erro ao adicionar %+v: %v", input, err)
+		}
+	}
+	if err := admin.Add(context.Background(), access_list_usecase.EntryInputDTO{List: "allow", Entry: "10.0.0.0/33"}); err == nil || err.Err != "bad_request" {
+		t.Fatalf("CIDR inválido deveria ser bad request, recebido %v", err)
+	}
+	time.Sleep(5 * time.Millisecond)
+
+	finalHandler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	handler := middleware.RateLimiterMiddleware(newTestPolicy(t), ratelimiter.NewLocalLimiter(0), middleware.WithAccessList(replica))(finalHandler)
+
+	serve := func(remote string) int {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.RemoteAddr = remote
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	// allow passa direto, sem consumir o limite de 1 por janela
+	for i := 0; i < 5; i++ {
+		if code := serve("10.10.3.4:1234"); code != http.StatusOK {
+			t.Fatalf("IP liberado deveria passar sem rate limit, recebido %d", code)
+		}
+	}
+	// deny vale sobre allow
+	if code := serve("10.10.0.66:1234"); code != http.StatusForbidden {
+		t.Fatalf("IP negado deveria receber 403, recebido %d", code)
+	}
+	if code := serve("[2001:db8::7]:443"); code != http.StatusForbidden {
+		t.Fatalf("faixa IPv6 negada deveria receber 403, recebido %d", code)
+	}
+	// fora das listas segue o rate limit
+	if serve("192.0.2.1:1234") != http.StatusOK || serve("192.0.2.1:1234") != http.StatusTooManyRequests {
+		t.Fatal("IP fora das listas deveria seguir o rate limit")
+	}
+
+	if err := admin.Remove(context.Background(), access_list_usecase.EntryInputDTO{List: "deny", Entry: "10.10.0.66"}); err != nil {
+		t.Fatalf("erro ao remover: %v", err)
+	}
+	time.Sleep(5 * time.Millisecond)
+	if code := serve("10.10.0.66:1234"); code != http.StatusOK {
+		t.Fatalf("IP removido da deny deveria voltar a passar, recebido %d", code)
+	}
+}
+
+func Test_RateLimiterMiddleware_KeyExtractor(t *testing.T) {
+	mr, err := miniredis.Run()
+	if err != nil {
+		t.Fatalf("erro ao iniciar miniredis: %v", err)
+	}
+	t.Cleanup(mr.Close)
+
+	redisClient := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	tolkenRepository := repository.NewTolkenDB(redisClient)
+	requestInfoRepository := repository.NewRequestInfoRepository(redisClient)
+	saveTolken(t, tolkenRepository, &tolken_entity.Tolken{TolkenString: "tolken-123"})
+
+	extractor, parseErr := key_entity.ParseKeyExtractor("bearer,query:api_key")
+	if parseErr != nil {
+		t.Fatalf("extractor válido recusado: %v", parseErr)
+	}
+	policy := policy_usecase.NewPolicyUsecase(
+		strategy_usecase.NewIPStrategyUsecaseWithLimits(limit_entity.StrategyLimits{Limit: 100, TTL: 10 * time.Second}, requestInfoRepository),
+		strategy_usecase.NewTokenStrategyUsecaseWithLimits(limit_entity.StrategyLimits{Limit: 3, TTL: 10 * time.Second, KeyExtractor: extractor}, tolkenRepository, requestInfoRepository),
+	)
+
+	finalHandler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	handler := middleware.RateLimiterMiddleware(policy, ratelimiter.NewLocalLimiter(0))(finalHandler)
+
+	serve := func(target string, headers map[string]string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, target, nil)
+		req.RemoteAddr = "10.0.0.20:1234"
+		for name, value := range headers {
+			req.Header.Set(name, value)
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	// Bearer e ?api_key= identificam o mesmo tolken e dividem o contador
+	if rec := serve("/", map[string]string{"Authorization": "Bearer tolken-123"}); rec.Code != http.StatusOK || rec.Header().Get("X-RateLimit-Limit") != "3" {
+		t.Fatalf("tolken do Bearer deveria usar o limite do tolken, recebido %d %v", rec.Code, rec.Header())
+	}
+	if rec := serve("/?api_key=tolken-123", nil); rec.Code != http.StatusOK {
+		t.Fatalf("tolken da query deveria passar, recebido %d", rec.Code)
+	}
+	if rec := serve("/", map[string]string{"Authorization": "Bearer tolken-123"}); rec.Code != http.StatusTooManyRequests {
+		t.Fatalf("os dois extractors deveriam dividir o contador, recebido %d", rec.Code)
+	}
+
+	// o API-KEY não é mais lido por essa strategy, a requisição cai no IP
+	if rec := serve("/", map[string]string{"API-KEY": "tolken-123"}); rec.Code != http.StatusOK || rec.Header().Get("X-RateLimit-Limit") != "100" {
+		t.Fatalf("sem o extractor configurado deveria usar a strategy de IP, recebido %d %v", rec.Code, rec.Header())
+	}
+}
+
+func Test_RateLimiterMiddleware_PolicyChain(t *testing.T) {
+	mr, err := miniredis.Run()
+	if err != nil {
+		t.Fatalf("erro ao iniciar miniredis: %v", err)
+	}
+	t.Cleanup(mr.Close)
+
+	redisClient := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	tolkenRepository := repository.NewTolkenDB(redisClient)
+	requestInfoRepository := repository.NewRequestInfoRepository(redisClient)
+	for _, tolken := range []string{"tolken-a", "tolken-b"} {
+		saveTolken(t, tolkenRepository, &tolken_entity.Tolken{TolkenString: tolken})
+	}
+
+	policy := policy_usecase.NewPolicyUsecase(
+		strategy_usecase.NewIPStrategyUsecaseWithLimits(limit_entity.StrategyLimits{Limit: 3, TTL: 10 * time.Second}, requestInfoRepository),
+		strategy_usecase.NewTokenStrategyUsecaseWithLimits(limit_entity.StrategyLimits{Limit: 4, TTL: 10 * time.Second}, tolkenRepository, requestInfoRepository),
+	)
+	policy.Chain = limit_entity.Chain{limit_entity.ChainTolken, limit_entity.ChainIP}
+
+	finalHandler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	handler := middleware.RateLimiterMiddleware(policy, ratelimiter.NewLocalLimiter(0))(finalHandler)
+
+	serve := func(remoteAddr string, tolken string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.RemoteAddr = remoteAddr
+		if tolken != "" {
+			req.Header.Set("API-KEY", tolken)
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	// a janela fixa admite limit-1: o IP admite 2 e o tolken 3
+	// o IP é o mais perto de esgotar, os headers descrevem ele
+	for i := 0; i < 2; i++ {
+		if rec := serve("10.0.0.1:1234", "tolken-a"); rec.Code != http.StatusOK || rec.Header().Get("X-RateLimit-Limit") != "3" {
+			t.Fatalf("requisição %d deveria passar pelo tolken e pelo IP, recebido %d %v", i+1, rec.Code, rec.Header())
+		}
+	}
+
+	// trocar de tolken não libera o IP esgotado
+	if rec := serve("10.0.0.1:1234", "tolken-b"); rec.Code != http.StatusTooManyRequests || rec.Header().Get("X-RateLimit-Limit") != "3" {
+		t.Fatalf("o IP esgotado deveria bloquear qualquer tolken, recebido %d %v", rec.Code, rec.Header())
+	}
+
+	// trocar de IP não libera o tolken esgotado
+	if rec := serve("10.0.0.2:1234", "tolken-a"); rec.Code != http.StatusOK {
+		t.Fatalf("o tolken ainda tinha cota, recebido %d", rec.Code)
+	}
+	if rec := serve("10.0.0.3:1234", "tolken-a"); rec.Code != http.StatusTooManyRequests || rec.Header().Get("X-RateLimit-Limit") != "4" {
+		t.Fatalf("o tolken esgotado deveria bloquear qualquer IP, recebido %d %v", rec.Code, rec.Header())
+	}
+
+	// as recusas não contaram nas outras strategies da cadeia
+	if rec := serve("10.0.0.3:1234", ""); rec.Code != http.StatusOK || rec.Header().Get("X-RateLimit-Remaining") != "1" {
+		t.Fatalf("a recusa pelo tolken não deveria consumir o IP, recebido %d %v", rec.Code, rec.Header())
+	}
+	for i, remoteAddr := range []string{"10.0.0.4:1234", "10.0.0.5:1234", "10.0.0.6:1234"} {
+		if rec := serve(remoteAddr, "tolken-b"); rec.Code != http.StatusOK {
+			t.Fatalf("requisição %d: a recusa pelo IP não deveria consumir o tolken-b, recebido %d", i+1, rec.Code)
+		}
+	}
+}
+
+func Test_RateLimiterMiddleware_InvalidTolkenFallback(t *testing.T) {
+	mr, err := miniredis.Run()
+	if err != nil {
+		t.Fatalf("erro ao iniciar miniredis: %v", err)
+	}
+	t.Cleanup(mr.Close)
+
+	redisClient := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	requestInfoRepository := repository.NewRequestInfoRepository(redisClient)
+
+	fallbacks, parseErr := limit_entity.ParseTolkenFallbackRules("GET /public=ip,/api=unauthorized")
+	if parseErr != nil {
+		t.Fatalf("regras válidas recusadas: %v", parseErr)
+	}
+	policy := policy_usecase.NewPolicyUsecase(
+		strategy_usecase.NewIPStrategyUsecaseWithLimits(limit_entity.StrategyLimits{Limit: 5, TTL: 10 * time.Second}, requestInfoRepository),
+		strategy_usecase.NewTokenStrategyUsecaseWithLimits(limit_entity.StrategyLimits{Limit: 10, TTL: 10 * time.Second}, repository.NewTolkenDB(redisClient), requestInfoRepository),
+	)
+	policy.TolkenFallback = fallbacks
+
+	finalHandler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	handler := middleware.RateLimiterMiddleware(policy, ratelimiter.NewLocalLimiter(0))(finalHandler)
+
+	serve := func(path string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req.RemoteAddr = "10.0.0.30:1234"
+		req.Header.Set("API-KEY", "tolken-expirado")
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	// a rota pública segue com o limite do IP
+	if rec := serve("/public/docs"); rec.Code != http.StatusOK || rec.Header().Get("X-RateLimit-Limit") != "5" {
+		t.Fatalf("tolken inválido na rota pública deveria usar a strategy de IP, recebido %d %v", rec.Code, rec.Header())
+	}
+
+	rec := serve("/api/orders")
+	if rec.Code != http.StatusUnauthorized || rec.Header().Get("WWW-Authenticate") == "" {
+		t.Fatalf("tolken inválido na api deveria receber 401 com WWW-Authenticate, recebido %d %v", rec.Code, rec.Header())
+	}
+
+	// sem regra continua o 400 de antes
+	if rec := serve("/"); rec.Code != http.StatusBadRequest || rec.Header().Get("WWW-Authenticate") != "" {
+		t.Fatalf("tolken inválido sem fallback deveria receber 400, recebido %d %v", rec.Code, rec.Header())
+	}
+
+	// na cadeia o tolken inválido sai e o IP continua contando
+	policy.Chain = limit_entity.Chain{limit_entity.ChainTolken, limit_entity.ChainIP}
+	if rec := serve("/public/docs"); rec.Code != http.StatusOK || rec.Header().Get("X-RateLimit-Remaining") != "2" {
+		t.Fatalf("na cadeia o tolken inválido deveria seguir só pelo IP, recebido %d %v", rec.Code, rec.Header())
+	}
+}
@@ -0,0 +1,85 @@
+package ratelimiter
+
+import (
+	"fmt"
+	"hash/maphash"
+	"sync"
+
+	"github.com/Higor-ViniciusDev/posgo_raterlimite/configuration/logger"
+)
+
+// ConcurrencyLimiter limita quantas requisições de uma mesma key podem estar em andamento ao mesmo tempo.
+// O estado fica em memória, dividido em shards como o memoryStore; cada instância conta apenas as
+// requisições que ela mesma está atendendo.
+type ConcurrencyLimiter struct {
+	shards []*inFlightShard
+	seed   maphash.Seed
+}
+
+type inFlightShard struct {
+	mu       sync.Mutex
+	inFlight map[string]int64
+}
+
+// NewConcurrencyLimiter cria o limiter de requisições em andamento (0 shards usa DefaultShards)
+func NewConcurrencyLimiter(shards int) *ConcurrencyLimiter {
+	if shards <= 0 {
+		shards = DefaultShards()
+	}
+
+	l := &ConcurrencyLimiter{
+		shards: make([]*inFlightShard, shards),
+		seed:   maphash.MakeSeed(),
+	}
+	for i := range l.shards {
+		l.shards[i] = &inFlightShard{inFlight: make(map[string]int64)}
+	}
+
+	return l
+}
+
+// Acquire reserva uma vaga para a key; false quando ela já tem max requisições em andamento.
+// Com max menor ou igual a 0 não há limite. Toda vaga reservada precisa ser devolvida com Release.
+func (l *ConcurrencyLimiter) Acquire(key string, max int64) bool {
+	if max <= 0 {
+		return true
+	}
+
+	shard := l.shard(key)
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+
+	if shard.inFlight[key] >= max {
+		logger.Info(fmt.Sprintf("Concurrency limit exceeded for key: %s", key))
+		return false
+	}
+
+	shard.inFlight[key]++
+	return true
+}
+
+// Release devolve a vaga reservada pelo Acquire; a key sai da memória quando não tem mais requisições
+func (l *ConcurrencyLimiter) Release(key string) {
+	shard := l.shard(key)
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+
+	if shard.inFlight[key] <= 1 {
+		delete(shard.inFlight, key)
+		return
+	}
+	shard.inFlight[key]--
+}
+
+// InFlight é quantas requisições da key estão em andamento
+func (l *ConcurrencyLimiter) InFlight(key string) int64 {
+	shard := l.shard(key)
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+
+	return shard.inFlight[key]
+}
+
+func (l *ConcurrencyLimiter) shard(key string) *inFlightShard {
+	return l.shards[maphash.String(l.seed, key)%uint64(len(l.shards))]
+}
@@ -0,0 +1,217 @@
+package ratelimiter
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/Higor-ViniciusDev/posgo_raterlimite/internal/entity/limit_entity"
+	"github.com/Higor-ViniciusDev/posgo_raterlimite/internal/internal_error"
+	"github.com/Higor-ViniciusDev/posgo_raterlimite/internal/usecase/policy_usecase"
+	"github.com/redis/go-redis/v9"
+)
+
+// Limiter decide se a key pode seguir com as regras informadas.
+// Requisição bloqueada volta com Decision.Allowed false e erro nil; o erro fica para falhas do limiter.
+type Limiter interface {
+	Allow(ctx context.Context, key string, rules Rules) (Decision, *internal_error.InternalError)
+	// AllowAll decide várias keys juntas (ex: tolken e IP da mesma requisição): a requisição só passa se
+	// couber em todas e a recusada por uma não consome a cota das outras. Devolve a decisão da check que
+	// recusou ou, liberada, a com menos cota restante, e o índice dela em checks.
+	AllowAll(ctx context.Context, checks []Check) (Decision, int, *internal_error.InternalError)
+}
+
+// Check é uma key com as regras dela, verificada junto com as outras no AllowAll
+type Check struct {
+	Key   string
+	Rules Rules
+}
+
+// Rules são as regras de limite de uma strategy.
+type Rules struct {
+	Limit     int64
+	TTL       time.Duration
+	Penalty   time.Duration
+	Algorithm limit_entity.Algorithm
+	Burst     int64
+	// PenaltyPolicy escala a penalidade nas infrações repetidas; sem degraus vale Penalty
+	PenaltyPolicy limit_entity.PenaltyPolicy
+	// Cost é quanto da cota a requisição consome (0 conta como 1)
+	Cost int64
+	// Windows são janelas extras verificadas junto com Limit/TTL (ex: 1000/h e 10000/dia),
+	// com o mesmo algoritmo e contador próprio. A requisição só passa se couber em todas.
+	Windows []limit_entity.Window
+}
+
+// Decision é o resultado do limiter para uma requisição.
+type Decision struct {
+	Allowed bool
+	// Limit é a cota total da key (a capacidade da rajada no token bucket e no gcra)
+	Limit int64
+	// Remaining é quanto da cota ainda pode ser usado agora
+	Remaining int64
+	// ResetAt é quando a cota volta a ficar cheia
+	ResetAt time.Time
+	// BlockedUntil é a partir de quando a key volta a ser aceita, zero quando liberada
+	BlockedUntil time.Time
+	// Window é a janela da decisão: a que bloqueou ou, quando liberada, a com menos cota restante
+	Window time.Duration
+}
+
+// RetryAfter é quanto o cliente bloqueado precisa esperar
+func (d Decision) RetryAfter() time.Duration {
+	if d.Allowed || d.BlockedUntil.IsZero() {
+		return 0
+	}
+	if wait := time.Until(d.BlockedUntil); wait > 0 {
+		return wait
+	}
+	return 0
+}
+
+// NewLimitExceededError é o erro devolvido ao cliente quando Decision.Allowed é false
+func NewLimitExceededError() *internal_error.InternalError {
+	return internal_error.NewManyRequestError("you have reached the maximum number of requests or actions allowed within a certain time frame")
+}
+
+// NewRules monta as regras a partir da strategy resolvida pela policy, com os limites devolvidos
+// junto com a key por GenerateKey (ex: o plano do tolken)
+func NewRules(strategy policy_usecase.RateLimitStrategy, limits limit_entity.Plan) Rules {
+	return Rules{
+		Limit:   limits.Limit,
+		TTL:     limits.TTL,
+		Penalty: limits.Penalty,
+		// a janela principal é Limit/TTL, as extras são verificadas junto
+		Windows:   limits.ExtraWindows,
+		Algorithm: strategy.GetAlgorithm(),
+		Burst:     strategy.GetBurst(),
+
+		PenaltyPolicy: strategy.GetPenaltyPolicy(),
+	}
+}
+
+// NewChecks monta a key e as regras de cada strategy resolvida, com o custo da requisição e a política
+// da rota aplicada em todas quando hasRoute. É a mesma montagem no middleware HTTP e no interceptor gRPC.
+func NewChecks(r *http.Request, resolved []policy_usecase.ResolvedStrategy, route limit_entity.RoutePolicy, hasRoute bool) []Check {
+	checks := make([]Check, len(resolved))
+	for i, item := range resolved {
+		rules := NewRules(item.Strategy, item.Limits)
+		rules.Cost = item.Strategy.GetCost(r)
+
+		key := item.Key
+		if hasRoute {
+			rules = rules.WithRoute(route)
+			key = route.Namespace(key)
+		}
+		checks[i] = Check{Key: key, Rules: rules}
+	}
+	return checks
+}
+
+// WithRoute aplica a política da rota sobre as regras da strategy, mantendo o que a rota não define
+func (r Rules) WithRoute(route limit_entity.RoutePolicy) Rules {
+	if route.Limit > 0 {
+		r.Limit = route.Limit
+	}
+	if route.TTL > 0 {
+		r.TTL = route.TTL
+	}
+	if route.Penalty > 0 {
+		r.Penalty = route.Penalty
+	}
+	if route.Algorithm != "" {
+		r.Algorithm = route.Algorithm
+	}
+	if route.Burst > 0 {
+		r.Burst = route.Burst
+	}
+	return r
+}
+
+// windowRules separa as regras de cada janela: a principal primeiro e depois as extras,
+// que herdam o resto das regras sem a rajada
+func (r Rules) windowRules() []Rules {
+	extras := r.Windows
+	r.Windows = nil
+
+	all := []Rules{r}
+	for _, window := range extras {
+		w := r
+		w.Limit = window.Limit
+		w.TTL = window.TTL
+		w.Burst = 0
+		all = append(all, w)
+	}
+	return all
+}
+
+// windowKey é a key do contador da janela, a principal mantém a key original
+func windowKey(key string, index int, rules Rules) string {
+	if index == 0 {
+		return key
+	}
+	return key + ":" + rules.TTL.String()
+}
+
+// cost é o custo efetivo da requisição, no mínimo 1
+func (r Rules) cost() int64 {
+	if r.Cost > 1 {
+		return r.Cost
+	}
+	return 1
+}
+
+// penalized indica se a key é bloqueada ao estourar o limite
+func (r Rules) penalized() bool {
+	return r.Penalty > 0 || r.PenaltyPolicy.Escalates()
+}
+
+// maxPenalty é o maior bloqueio que as regras podem aplicar
+func (r Rules) maxPenalty() time.Duration {
+	if r.PenaltyPolicy.Escalates() {
+		return r.PenaltyPolicy.Max()
+	}
+	return r.Penalty
+}
+
+// store guarda o estado das keys e decide cada requisição. A memória local atende uma única
+// instância; o redis compartilha o estado entre as réplicas.
+type store interface {
+	allow(ctx context.Context, key string, rules Rules) (Decision, *internal_error.InternalError)
+	allowAll(ctx context.Context, checks []Check) (Decision, int, *internal_error.InternalError)
+	stop()
+}
+
+// DirectLimiter consulta o store na própria goroutine da requisição, sem o pool de workers.
+type DirectLimiter struct {
+	store store
+}
+
+// NewLocalLimiter cria um limiter direto com os contadores em memória, divididos em shards
+// (0 usa DefaultShards)
+func NewLocalLimiter(shards int) *DirectLimiter {
+	return &DirectLimiter{store: newMemoryStore(shards)}
+}
+
+// NewRedisLimiter cria um limiter direto com os contadores no redis
+func NewRedisLimiter(client *redis.Client) *DirectLimiter {
+	return &DirectLimiter{store: &redisStore{client: client}}
+}
+
+func (l *DirectLimiter) Allow(ctx context.Context, key string, rules Rules) (Decision, *internal_error.InternalError) {
+	return l.store.allow(ctx, key, rules)
+}
+
+func (l *DirectLimiter) AllowAll(ctx context.Context, checks []Check) (Decision, int, *internal_error.InternalError) {
+	return l.store.allowAll(ctx, checks)
+}
+
+// Stop descarta o estado local do limiter
+func (l *DirectLimiter) Stop() {
+	l.store.stop()
+}
+
+// errEmptyChecks é devolvido pelo allowAll sem nenhuma key para decidir
+func errEmptyChecks() *internal_error.InternalError {
+	return internal_error.NewInternalServerError("rate limit checks is empty")
+}
@@ -3,21 +3,31 @@ package ratelimiter
 import (
 	"context"
 	"fmt"
+	"hash/maphash"
+	"runtime"
 	"sync"
 	"time"
 
 	"github.com/Higor-ViniciusDev/posgo_raterlimite/configuration/logger"
+	"github.com/Higor-ViniciusDev/posgo_raterlimite/internal/entity/limit_entity"
 	"github.com/Higor-ViniciusDev/posgo_raterlimite/internal/internal_error"
-	"github.com/Higor-ViniciusDev/posgo_raterlimite/internal/usecase/policy_usecase"
 )
 
 type RateLimitMessage struct {
-	Ctx       context.Context
-	Key       string
-	Limit     int64
-	TTL       time.Duration
-	Strategy  policy_usecase.RateLimitStrategy
-	ReplyChan chan *internal_error.InternalError
+	Ctx   context.Context
+	Key   string
+	Rules Rules
+	// Checks preenchido decide as keys juntas (AllowAll) no lugar de Key/Rules
+	Checks    []Check
+	ReplyChan chan RateLimitReply
+}
+
+// RateLimitReply é a resposta do worker: Err só é preenchido quando o limiter falha.
+type RateLimitReply struct {
+	Decision Decision
+	// Index é a check da decisão quando a mensagem tem Checks
+	Index int
+	Err   *internal_error.InternalError
 }
 
 // Counter guarda o contador em memória.
@@ -25,22 +35,67 @@ type Counter struct {
 	Count        int64
 	BlockedUntil time.Time
 	KeyCreatedAt time.Time
+
+	// Estado do TokenBucket: tokens disponiveis e ultimo reabastecimento
+	Tokens     float64
+	LastRefill time.Time
+
+	// SlidingLog: horário das requisições aceitas dentro do último TTL
+	Requests []time.Time
+	// SlidingWindowCounter: total da janela anterior (Count e KeyCreatedAt são a janela atual)
+	PreviousCount int64
+
+	// Penalidade escalonada: infrações recentes e horário da última
+	Offenses    int64
+	LastOffense time.Time
 }
 
+// RateLimiter é o limiter com pool de workers: as requisições entram pelo InputChan
+// e a decisão volta pelo ReplyChan da mensagem.
 type RateLimiter struct {
 	InputChan chan RateLimitMessage
 	workers   int
 
+	store  store
+	closed chan struct{}
+}
+
+// memoryStore guarda os contadores em memória divididos em shards: cada key cai sempre no mesmo
+// shard pelo hash e cada shard tem o próprio mutex, então keys diferentes não disputam o mesmo lock.
+type memoryStore struct {
+	shards []*memoryShard
+	seed   maphash.Seed
+	closed chan struct{}
+}
+
+// memoryShard é uma fatia das keys com lock próprio.
+type memoryShard struct {
 	mu       sync.Mutex
 	requests map[string]*Counter
-	closed   chan struct{}
+	tats     map[string]time.Time // GCRA: tempo teórico de chegada por key
+	closed   <-chan struct{}
 }
 
+// NewRateLimiter cria o pool de workers com os contadores em memória, usando DefaultShards
 func NewRateLimiter(workers int, queueSize int) *RateLimiter {
+	return newRateLimiter(newMemoryStore(DefaultShards()), workers, queueSize)
+}
+
+// NewShardedRateLimiter é o NewRateLimiter com a quantidade de shards informada
+func NewShardedRateLimiter(workers int, queueSize int, shards int) *RateLimiter {
+	return newRateLimiter(newMemoryStore(shards), workers, queueSize)
+}
+
+// DefaultShards acompanha a quantidade de núcleos, com folga para reduzir colisões entre keys quentes
+func DefaultShards() int {
+	return runtime.GOMAXPROCS(0) * 4
+}
+
+func newRateLimiter(st store, workers int, queueSize int) *RateLimiter {
 	rl := &RateLimiter{
 		workers:   workers,
 		InputChan: make(chan RateLimitMessage, queueSize),
-		requests:  make(map[string]*Counter),
+		store:     st,
 		closed:    make(chan struct{}),
 	}
 
@@ -51,133 +106,709 @@ func NewRateLimiter(workers int, queueSize int) *RateLimiter {
 	return rl
 }
 
-// Stop fecha o rate limiter (fecha o canal de entrada).
-func (rl *RateLimiter) Stop() {
-	logger.Info("Canal encerrando")
+func newMemoryStore(shards int) *memoryStore {
+	if shards <= 0 {
+		shards = DefaultShards()
+	}
+
+	s := &memoryStore{
+		shards: make([]*memoryShard, shards),
+		seed:   maphash.MakeSeed(),
+		closed: make(chan struct{}),
+	}
+
+	for i := range s.shards {
+		s.shards[i] = &memoryShard{
+			requests: make(map[string]*Counter),
+			tats:     make(map[string]time.Time),
+			closed:   s.closed,
+		}
+	}
+
+	return s
+}
+
+// Allow envia a requisição ao pool de workers e espera a decisão
+func (rl *RateLimiter) Allow(ctx context.Context, key string, rules Rules) (Decision, *internal_error.InternalError) {
+	result := rl.send(ctx, RateLimitMessage{Ctx: ctx, Key: key, Rules: rules})
+	return result.Decision, result.Err
+}
+
+// AllowAll envia as keys em uma única mensagem, o worker decide todas juntas
+func (rl *RateLimiter) AllowAll(ctx context.Context, checks []Check) (Decision, int, *internal_error.InternalError) {
+	result := rl.send(ctx, RateLimitMessage{Ctx: ctx, Checks: checks})
+	return result.Decision, result.Index, result.Err
+}
 
-	// Saída de dados parados em buffer não processados
-	rl.mu.Lock()
-	defer rl.mu.Unlock()
+func (rl *RateLimiter) send(ctx context.Context, msg RateLimitMessage) RateLimitReply {
+	reply := make(chan RateLimitReply, 1)
+	msg.ReplyChan = reply
 
-	for key := range rl.requests {
-		delete(rl.requests, key)
+	select {
+	case rl.InputChan <- msg:
+	case <-rl.closed:
+		return RateLimitReply{Err: errShutdown()}
+	case <-ctx.Done():
+		return RateLimitReply{Err: internal_error.NewRequestTimeoutError("request canceled")}
 	}
 
-	for i := 0; i < rl.workers; i++ {
-		rl.InputChan <- RateLimitMessage{}
+	select {
+	case result := <-reply:
+		return result
+	case <-rl.closed:
+		return RateLimitReply{Err: errShutdown()}
+	case <-ctx.Done():
+		return RateLimitReply{Err: internal_error.NewRequestTimeoutError("request canceled")}
 	}
+}
+
+// Stop encerra os workers. O InputChan continua aberto, assim um Allow em andamento não envia
+// para um canal fechado; quem ainda espera a decisão recebe erro de shutdown.
+func (rl *RateLimiter) Stop() {
+	logger.Info("Canal encerrando")
 
 	close(rl.closed)
-	close(rl.InputChan)
+
+	rl.store.stop()
 }
 
 func (rl *RateLimiter) worker() {
-	for msg := range rl.InputChan {
-		// se o rate limiter estiver sendo fechado, tenta sair
+	for {
+		var msg RateLimitMessage
 		select {
 		case <-rl.closed:
-			select {
-			case msg.ReplyChan <- internal_error.NewInternalServerError("server shutdown"):
-			default:
-			}
 			return
+		case msg = <-rl.InputChan:
+		}
+
+		ctx := msg.Ctx
+		if ctx == nil {
+			ctx = context.Background()
+		}
+
+		var result RateLimitReply
+		if len(msg.Checks) > 0 {
+			result.Decision, result.Index, result.Err = rl.store.allowAll(ctx, msg.Checks)
+		} else {
+			result.Decision, result.Err = rl.store.allow(ctx, msg.Key, msg.Rules)
+		}
+
+		select {
+		case msg.ReplyChan <- result:
 		default:
 		}
+	}
+}
+
+// errShutdown é devolvido a quem chama o limiter depois do Stop
+func errShutdown() *internal_error.InternalError {
+	return internal_error.NewInternalServerError("server shutdown")
+}
+
+// stop descarta os contadores e encerra as rotinas de expiração
+func (s *memoryStore) stop() {
+	for _, shard := range s.shards {
+		shard.mu.Lock()
+		for key := range shard.requests {
+			delete(shard.requests, key)
+		}
+		for key := range shard.tats {
+			delete(shard.tats, key)
+		}
+		shard.mu.Unlock()
+	}
 
-		rl.mu.Lock()
+	close(s.closed)
+}
 
-		counter, exists := rl.requests[msg.Key]
-		now := time.Now()
+func (s *memoryStore) allow(ctx context.Context, key string, rules Rules) (Decision, *internal_error.InternalError) {
+	// as janelas da key ficam no shard da key original, todas sob o mesmo lock
+	return s.shard(key).allow(key, rules), nil
+}
 
-		// Verifica se existe chafe e se está bloqueado com base no blockedUntil e no tempo atual
-		if exists && !counter.BlockedUntil.IsZero() && now.Before(counter.BlockedUntil) {
-			rl.mu.Unlock()
+// allowAll trava os shards de todas as keys, sempre na ordem dos índices para duas cadeias não
+// se esperarem, e desfaz as keys já consumidas quando uma delas recusa a requisição.
+func (s *memoryStore) allowAll(ctx context.Context, checks []Check) (Decision, int, *internal_error.InternalError) {
+	switch len(checks) {
+	case 0:
+		return Decision{}, 0, errEmptyChecks()
+	case 1:
+		decision, err := s.allow(ctx, checks[0].Key, checks[0].Rules)
+		return decision, 0, err
+	}
 
-			// Avisar que o limite foi excedido
-			logger.Info(fmt.Sprintf("Rate limit exceeded (blocked) for key: %s", msg.Key))
-			select {
-			case msg.ReplyChan <- internal_error.NewManyRequestError("you have reached the maximum number of requests or actions allowed within a certain time frame"):
-			default:
-			}
-			continue
+	locked := make([]bool, len(s.shards))
+	for _, check := range checks {
+		locked[s.shardIndex(check.Key)] = true
+	}
+	for i, shard := range s.shards {
+		if locked[i] {
+			shard.mu.Lock()
+			defer shard.mu.Unlock()
 		}
+	}
+
+	var best Decision
+	bestIndex := 0
+	var consumed []windowSnapshot
+	for i, check := range checks {
+		d, snapshots := s.shard(check.Key).allowWindows(check.Key, check.Rules, true)
+		if !d.Allowed {
+			restoreAll(consumed)
+			return d, i, nil
+		}
+		consumed = append(consumed, snapshots...)
+
+		if i == 0 || d.Remaining < best.Remaining {
+			best, bestIndex = d, i
+		}
+	}
+
+	return best, bestIndex, nil
+}
+
+func (s *memoryStore) shard(key string) *memoryShard {
+	return s.shards[s.shardIndex(key)]
+}
+
+func (s *memoryStore) shardIndex(key string) uint64 {
+	return maphash.String(s.seed, key) % uint64(len(s.shards))
+}
+
+// windowSnapshot é o estado de uma janela antes da requisição, para devolver a cota consumida
+type windowSnapshot struct {
+	shard   *memoryShard
+	key     string
+	counter *Counter // cópia do contador, nil quando a key não existia
+	tat     time.Time
+	hasTAT  bool
+}
+
+// allow verifica todas as janelas da key de uma vez. A primeira janela estourada recusa a requisição
+// e as anteriores voltam ao estado de antes, então nenhuma janela conta uma requisição recusada.
+func (s *memoryShard) allow(key string, rules Rules) Decision {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	decision, _ := s.allowWindows(key, rules, false)
+	return decision
+}
+
+// allowWindows é o allow com o lock do shard já adquirido. Com keep, liberada devolve o estado anterior
+// das janelas consumidas, para o allowAll desfazer se outra key da cadeia recusar.
+func (s *memoryShard) allowWindows(key string, rules Rules, keep bool) (Decision, []windowSnapshot) {
+	windows := rules.windowRules()
+	snapshots := keep || len(windows) > 1
+
+	var best Decision
+	var consumed []windowSnapshot
+	for i, w := range windows {
+		k := windowKey(key, i, w)
+
+		var snapshot windowSnapshot
+		if snapshots {
+			snapshot = s.snapshot(k)
+		}
+
+		d := s.allowWindow(k, w)
+		d.Window = w.TTL
+		if !d.Allowed {
+			restoreAll(consumed)
+			return d, nil
+		}
+		if snapshots {
+			consumed = append(consumed, snapshot)
+		}
+
+		if i == 0 || d.Remaining < best.Remaining {
+			best = d
+		}
+	}
+
+	return best, consumed
+}
+
+// allowWindow aplica o algoritmo das regras na janela, com o lock do shard já adquirido
+func (s *memoryShard) allowWindow(key string, rules Rules) Decision {
+	if rules.Algorithm == limit_entity.GCRA {
+		return s.allowGCRA(key, rules)
+	}
+	return s.allowCounter(key, rules)
+}
+
+func (s *memoryShard) snapshot(key string) windowSnapshot {
+	snapshot := windowSnapshot{shard: s, key: key}
+	if counter, ok := s.requests[key]; ok {
+		copied := *counter
+		copied.Requests = append([]time.Time(nil), counter.Requests...)
+		snapshot.counter = &copied
+	}
+	snapshot.tat, snapshot.hasTAT = s.tats[key]
+	return snapshot
+}
+
+// restoreAll desfaz do último para o primeiro, a mesma key pode aparecer mais de uma vez na cadeia
+func restoreAll(snapshots []windowSnapshot) {
+	for i := len(snapshots) - 1; i >= 0; i-- {
+		snapshots[i].shard.restore(snapshots[i])
+	}
+}
+
+// restore volta a janela ao snapshot. A rotina de expiração de um contador removido encerra ao ver
+// que a key não existe mais ou aponta para outro contador, sem apagar o contador novo.
+func (s *memoryShard) restore(snapshot windowSnapshot) {
+	if snapshot.counter == nil {
+		delete(s.requests, snapshot.key)
+	} else if counter, ok := s.requests[snapshot.key]; ok {
+		*counter = *snapshot.counter
+	}
+
+	if snapshot.hasTAT {
+		s.tats[snapshot.key] = snapshot.tat
+	} else {
+		delete(s.tats, snapshot.key)
+	}
+}
+
+// allowCounter aplica o algoritmo das regras sobre o contador da key
+func (s *memoryShard) allowCounter(key string, rules Rules) Decision {
+	counter, exists := s.requests[key]
+	now := time.Now()
+
+	// Verifica se existe chafe e se está bloqueado com base no blockedUntil e no tempo atual
+	if exists && !counter.BlockedUntil.IsZero() && now.Before(counter.BlockedUntil) {
+		// Avisar que o limite foi excedido
+		logger.Info(fmt.Sprintf("Rate limit exceeded (blocked) for key: %s", key))
+		return counter.decision(now, rules, false)
+	}
+
+	if !exists {
+		counter = &Counter{
+			Count: 0,
+		}
+		s.requests[key] = counter
+
+		go s.expireKey(key, counter, rules)
+	}
+
+	var allowed bool
+	switch rules.Algorithm {
+	case limit_entity.TokenBucket:
+		allowed = counter.takeToken(now, rules)
+	case limit_entity.SlidingLog:
+		allowed = counter.appendSlidingLog(now, rules)
+	case limit_entity.SlidingWindowCounter:
+		allowed = counter.incrementSlidingWindow(now, rules)
+	default:
+		allowed = counter.incrementFixedWindow(now, rules)
+	}
+
+	if !allowed {
+		// excedeu
+		logger.Info(fmt.Sprintf("Rate limit exceeded for key: %s", key))
+	}
+
+	return counter.decision(now, rules, allowed)
+}
 
-		if !exists {
-			counter = &Counter{
-				Count: 0,
+// allowGCRA decide a requisição pelo Generic Cell Rate Algorithm.
+// Cada requisição avança o TAT em um intervalo de emissão (TTL/limite); a requisição passa enquanto
+// o TAT não estiver mais adiantado que a tolerância de rajada. Como só o TAT é guardado, o tempo de
+// espera do cliente bloqueado é exato: TAT - tolerância - agora.
+func (s *memoryShard) allowGCRA(key string, rules Rules) Decision {
+	now := time.Now()
+	if rules.Limit <= 0 || rules.TTL <= 0 {
+		return Decision{Allowed: true, Limit: rules.Limit, ResetAt: now}
+	}
+
+	emission := rules.TTL / time.Duration(rules.Limit)
+	capacity := bucketCapacity(rules)
+	tolerance := emission * time.Duration(capacity-1)
+
+	tat, exists := s.tats[key]
+	if tat.Before(now) {
+		tat = now
+	}
+
+	// o custo avança o TAT em um intervalo de emissão por unidade
+	increment := emission * time.Duration(rules.cost())
+	if allowAt := tat.Add(increment - emission - tolerance); now.Before(allowAt) {
+		// A penalidade empurra o TAT, mantendo a key bloqueada sem guardar outro estado.
+		// Espera maior que um intervalo de emissão indica que a penalidade já foi aplicada.
+		if rules.penalized() && allowAt.Sub(now) <= emission {
+			// as infrações ficam em um Counter, o GCRA não usa o resto dele
+			counter, ok := s.requests[key]
+			if !ok {
+				counter = &Counter{}
+				s.requests[key] = counter
+				go s.expireKey(key, counter, rules)
 			}
-			rl.requests[msg.Key] = counter
-
-			go func(key string, ttl time.Duration, penality time.Duration) {
-				maxTime := ttl
-				if penality > maxTime {
-					maxTime = penality
-				}
-				maxTime += 1 * time.Second // Buffer seguranca caso a key ainda esteja em uso após um segundo que foi definido
-
-				timer := time.NewTimer(maxTime)
-				select {
-				case <-timer.C:
-					rl.mu.Lock()
-					if c, ok := rl.requests[key]; ok {
-						now := time.Now()
-						// Verifica se o contador ainda está expirado antes de deletar
-						penalityExpired := c.BlockedUntil.IsZero() || now.After(c.BlockedUntil)
-						tllExpired := now.Sub(c.KeyCreatedAt) > ttl
-
-						if penalityExpired && tllExpired {
-							delete(rl.requests, key)
-						}
-					}
-					rl.mu.Unlock()
-				case <-rl.closed:
-					timer.Stop()
-				}
-			}(msg.Key, msg.TTL, msg.Strategy.GetPenaltyDuration())
-		} else {
-			// Se o contador existir, mas o bloqueio expirou, resetar o contador
-			penalityExpired := counter.BlockedUntil.IsZero() || now.After(counter.BlockedUntil)
-			tllExpired := now.Sub(counter.KeyCreatedAt) > msg.TTL
-
-			if penalityExpired && tllExpired {
-				// Se ambos expiraram, resetar o contador e o bloqueio
-				counter.Count = 0
-				counter.BlockedUntil = time.Time{}
-				counter.KeyCreatedAt = now
-			} else if penalityExpired && !tllExpired {
-				// Se o bloqueio expirou, mas o TTL não, apenas resetar o bloqueio
-				counter.BlockedUntil = time.Time{}
+
+			penalty := counter.nextPenalty(now, rules)
+			tat = now.Add(penalty + tolerance)
+			s.tats[key] = tat
+			allowAt = now.Add(penalty)
+		}
+
+		logger.Info(fmt.Sprintf("Rate limit exceeded for key: %s", key))
+		return Decision{Limit: int64(capacity), ResetAt: tat, BlockedUntil: allowAt}
+	}
+
+	tat = tat.Add(increment)
+	s.tats[key] = tat
+	if !exists {
+		go s.expireTAT(key)
+	}
+
+	// cabem mais requisições enquanto o TAT não passar de agora + tolerância
+	// (folga negativa significa cota esgotada, a divisão inteira arredondaria para cima)
+	var remaining int64
+	if slack := now.Add(tolerance).Sub(tat); slack >= 0 {
+		remaining = int64(slack/emission) + 1
+	}
+	return Decision{Allowed: true, Limit: int64(capacity), Remaining: remaining, ResetAt: tat}
+}
+
+// expireTAT remove a key quando o TAT fica no passado, a partir daí ela equivale a uma key nova
+func (s *memoryShard) expireTAT(key string) {
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-timer.C:
+			s.mu.Lock()
+			tat, ok := s.tats[key]
+			now := time.Now()
+			if !ok || !tat.After(now) {
+				delete(s.tats, key)
+				s.mu.Unlock()
+				return
 			}
+			s.mu.Unlock()
+			timer.Reset(tat.Sub(now) + 1*time.Second)
+		case <-s.closed:
+			return
 		}
+	}
+}
 
-		counter.Count++
-		// compara com o limit enviado na mensagem
-		if counter.Count >= msg.Limit {
-			//Se bloquear request, adicionar penalidade de timer para o bloqueio temporario
-			if counter.BlockedUntil.IsZero() {
-				counter.BlockedUntil = now.Add(msg.Strategy.GetPenaltyDuration())
+// expireKey remove a key da memória depois que a janela, a penalidade e o histórico de infrações expiraram.
+// Enquanto a key continuar em uso a verificação é reagendada.
+func (s *memoryShard) expireKey(key string, counter *Counter, rules Rules) {
+	maxTime := idleDuration(rules)
+	if penalty := rules.maxPenalty(); penalty > maxTime {
+		maxTime = penalty
+	}
+	if rules.PenaltyPolicy.Escalates() && rules.PenaltyPolicy.Lookback > maxTime {
+		maxTime = rules.PenaltyPolicy.Lookback
+	}
+	maxTime += 1 * time.Second // Buffer seguranca caso a key ainda esteja em uso após um segundo que foi definido
+
+	timer := time.NewTimer(maxTime)
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-timer.C:
+			s.mu.Lock()
+			c, ok := s.requests[key]
+			// a key removida pelo restore e criada de novo tem outro contador, com a própria rotina
+			if !ok || c != counter {
+				s.mu.Unlock()
+				return
 			}
 
-			logger.Info(fmt.Sprintf("Rate limit exceeded for key: %s", msg.Key))
-			rl.mu.Unlock()
-			// excedeu
-			select {
-			case msg.ReplyChan <- internal_error.NewManyRequestError("you have reached the maximum number of requests or actions allowed within a certain time frame"):
-			default:
+			now := time.Now()
+			// Verifica se o contador ainda está expirado antes de deletar
+			penalityExpired := c.BlockedUntil.IsZero() || now.After(c.BlockedUntil)
+			forgiven := rules.PenaltyPolicy.Decay(c.Offenses, now.Sub(c.LastOffense)) == 0
+
+			if penalityExpired && forgiven && c.idle(now, rules) {
+				delete(s.requests, key)
+				s.mu.Unlock()
+				return
 			}
-			continue
+			s.mu.Unlock()
+			timer.Reset(maxTime)
+		case <-s.closed:
+			return
 		}
+	}
+}
 
-		rl.mu.Unlock()
+// incrementFixedWindow conta a requisição na janela atual, resetando o contador quando o TTL expira.
+// Contador novo começa com KeyCreatedAt zerado e abre a janela na primeira requisição.
+func (c *Counter) incrementFixedWindow(now time.Time, rules Rules) bool {
+	// Se o contador existir, mas o bloqueio expirou, resetar o contador
+	penalityExpired := c.BlockedUntil.IsZero() || now.After(c.BlockedUntil)
+	tllExpired := now.Sub(c.KeyCreatedAt) > rules.TTL
 
-		// Tudo ok
-		select {
-		case msg.ReplyChan <- nil:
-		default:
+	if penalityExpired && tllExpired {
+		// Se ambos expiraram, resetar o contador e o bloqueio
+		c.Count = 0
+		c.BlockedUntil = time.Time{}
+		c.KeyCreatedAt = now
+	} else if penalityExpired && !tllExpired {
+		// Se o bloqueio expirou, mas o TTL não, apenas resetar o bloqueio
+		c.BlockedUntil = time.Time{}
+	}
+
+	c.Count += rules.cost()
+
+	// compara com o limit das regras
+	if c.Count >= rules.Limit {
+		//Se bloquear request, adicionar penalidade de timer para o bloqueio temporario
+		if c.BlockedUntil.IsZero() {
+			c.BlockedUntil = now.Add(c.nextPenalty(now, rules))
 		}
-		//Para fins de info, apenas salvar os que deram sucesso é requisito do projeto
-		if err := msg.Strategy.SaveRequestInfo(context.Background(), msg.Key); err != nil {
-			logger.Error("erro ao salvar request info", err)
+		return false
+	}
+
+	return true
+}
+
+// takeToken reabastece o bucket pelo tempo decorrido e consome um token por unidade de custo.
+// Bucket vazio só aplica a penalidade quando a strategy tiver uma configurada.
+func (c *Counter) takeToken(now time.Time, rules Rules) bool {
+	capacity := bucketCapacity(rules)
+
+	if c.LastRefill.IsZero() || rules.TTL <= 0 {
+		c.Tokens = capacity
+	} else {
+		c.Tokens += now.Sub(c.LastRefill).Seconds() * float64(rules.Limit) / rules.TTL.Seconds()
+		if c.Tokens > capacity {
+			c.Tokens = capacity
 		}
 	}
+	c.LastRefill = now
+
+	cost := float64(rules.cost())
+	if c.Tokens < cost {
+		c.applyPenalty(now, rules)
+		return false
+	}
+
+	c.Tokens -= cost
+	return true
+}
+
+// appendSlidingLog descarta os horários fora do último TTL e aceita a requisição enquanto o log tiver espaço
+func (c *Counter) appendSlidingLog(now time.Time, rules Rules) bool {
+	windowStart := now.Add(-rules.TTL)
+
+	expired := 0
+	for expired < len(c.Requests) && !c.Requests[expired].After(windowStart) {
+		expired++
+	}
+	c.Requests = c.Requests[expired:]
+
+	if int64(len(c.Requests))+rules.cost() > rules.Limit {
+		c.applyPenalty(now, rules)
+		return false
+	}
+
+	// cada unidade do custo ocupa uma posição do log
+	for range rules.cost() {
+		c.Requests = append(c.Requests, now)
+	}
+	return true
+}
+
+// incrementSlidingWindow estima as requisições do último TTL somando a janela atual com a fração
+// da anterior que ainda se sobrepõe, evitando a rajada de 2x o limite na virada da janela fixa
+func (c *Counter) incrementSlidingWindow(now time.Time, rules Rules) bool {
+	c.advanceWindow(now, rules.TTL)
+
+	if c.slidingEstimate(now, rules.TTL)+float64(rules.cost()) > float64(rules.Limit) {
+		c.applyPenalty(now, rules)
+		return false
+	}
+
+	c.Count += rules.cost()
+	return true
+}
+
+// advanceWindow move a janela atual para a que contém now, a atual vira a anterior
+func (c *Counter) advanceWindow(now time.Time, ttl time.Duration) {
+	if c.KeyCreatedAt.IsZero() || ttl <= 0 {
+		c.KeyCreatedAt = now
+		return
+	}
+
+	elapsed := now.Sub(c.KeyCreatedAt) / ttl
+	switch {
+	case elapsed <= 0:
+		return
+	case elapsed == 1:
+		c.PreviousCount = c.Count
+	default:
+		c.PreviousCount = 0
+	}
+	c.Count = 0
+	c.KeyCreatedAt = c.KeyCreatedAt.Add(elapsed * ttl)
+}
+
+func (c *Counter) slidingEstimate(now time.Time, ttl time.Duration) float64 {
+	if ttl <= 0 {
+		return float64(c.Count)
+	}
+	overlap := 1 - float64(now.Sub(c.KeyCreatedAt))/float64(ttl)
+	return float64(c.PreviousCount)*overlap + float64(c.Count)
+}
+
+// applyPenalty bloqueia a key quando a strategy tem penalidade configurada
+func (c *Counter) applyPenalty(now time.Time, rules Rules) {
+	if rules.penalized() {
+		c.BlockedUntil = now.Add(c.nextPenalty(now, rules))
+	}
+}
+
+// nextPenalty registra a infração e devolve a penalidade dela. As infrações antigas decaem um nível
+// a cada lookback sem novas infrações; sem degraus a penalidade é a fixa da strategy.
+func (c *Counter) nextPenalty(now time.Time, rules Rules) time.Duration {
+	policy := rules.PenaltyPolicy
+	if !policy.Escalates() {
+		return rules.Penalty
+	}
+
+	c.Offenses = policy.Decay(c.Offenses, now.Sub(c.LastOffense)) + 1
+	c.LastOffense = now
+
+	return policy.Duration(c.Offenses)
+}
+
+// decision monta a resposta com a cota restante e quando ela se renova
+func (c *Counter) decision(now time.Time, rules Rules, allowed bool) Decision {
+	d := Decision{Allowed: allowed, Limit: rules.Limit, ResetAt: now}
+
+	switch rules.Algorithm {
+	case limit_entity.TokenBucket:
+		capacity := bucketCapacity(rules)
+		d.Limit = int64(capacity)
+		d.Remaining = int64(c.Tokens)
+		if rules.Limit > 0 {
+			// bucket cheio de novo
+			d.ResetAt = now.Add(time.Duration((capacity - c.Tokens) * float64(rules.TTL) / float64(rules.Limit)))
+		}
+	case limit_entity.SlidingLog:
+		d.Remaining = rules.Limit - int64(len(c.Requests))
+		if len(c.Requests) > 0 {
+			// a requisição mais nova sai do log
+			d.ResetAt = c.Requests[len(c.Requests)-1].Add(rules.TTL)
+		}
+	case limit_entity.SlidingWindowCounter:
+		d.Remaining = int64(float64(rules.Limit) - c.slidingEstimate(now, rules.TTL))
+		d.ResetAt = c.KeyCreatedAt.Add(rules.TTL)
+	default:
+		// a requisição que leva o contador ao limit já é recusada
+		d.Remaining = rules.Limit - c.Count - 1
+		d.ResetAt = c.KeyCreatedAt.Add(rules.TTL)
+	}
+
+	if !allowed {
+		d.Remaining = 0
+		d.BlockedUntil = now.Add(c.retryAfter(now, rules))
+	}
+	if d.Remaining < 0 {
+		d.Remaining = 0
+	}
+	if d.ResetAt.Before(d.BlockedUntil) {
+		d.ResetAt = d.BlockedUntil
+	}
+	return d
+}
+
+// retryAfter calcula quanto tempo falta para a key aceitar uma nova requisição
+func (c *Counter) retryAfter(now time.Time, rules Rules) time.Duration {
+	var wait time.Duration
+
+	switch rules.Algorithm {
+	case limit_entity.TokenBucket:
+		if rules.Limit > 0 {
+			// tempo para reabastecer os tokens que faltam para o custo
+			wait = time.Duration((float64(rules.cost()) - c.Tokens) * float64(rules.TTL) / float64(rules.Limit))
+		}
+	case limit_entity.SlidingLog:
+		// as requisições mais antigas precisam sair do log até caber o custo
+		if leaving := int64(len(c.Requests)) - (rules.Limit - rules.cost()); leaving > 0 {
+			if leaving > int64(len(c.Requests)) {
+				leaving = int64(len(c.Requests))
+			}
+			wait = c.Requests[leaving-1].Add(rules.TTL).Sub(now)
+		}
+	case limit_entity.SlidingWindowCounter:
+		wait = c.slidingRetryAfter(now, rules)
+	default:
+		// o contador só zera quando a janela termina
+		wait = c.KeyCreatedAt.Add(rules.TTL).Sub(now)
+	}
+
+	if blocked := c.BlockedUntil.Sub(now); blocked > wait {
+		wait = blocked
+	}
+	if wait < 0 {
+		wait = 0
+	}
+	return wait
+}
+
+// slidingRetryAfter resolve em que momento a estimativa da janela deslizante abre espaço para mais uma requisição
+func (c *Counter) slidingRetryAfter(now time.Time, rules Rules) time.Duration {
+	if rules.TTL <= 0 || rules.Limit <= 0 {
+		return 0
+	}
+
+	windowEnd := c.KeyCreatedAt.Add(rules.TTL)
+	free := float64(rules.Limit - rules.cost())
+
+	// Ainda na janela atual: espera o peso da anterior cair o suficiente
+	if float64(c.Count) <= free && c.PreviousCount > 0 {
+		fraction := 1 - (free-float64(c.Count))/float64(c.PreviousCount)
+		return c.KeyCreatedAt.Add(time.Duration(fraction * float64(rules.TTL))).Sub(now)
+	}
+
+	// Só na próxima janela, quando a atual vira a anterior
+	if c.Count <= 0 {
+		return windowEnd.Sub(now)
+	}
+	fraction := 1 - free/float64(c.Count)
+	if fraction < 0 {
+		fraction = 0
+	}
+	return windowEnd.Add(time.Duration(fraction * float64(rules.TTL))).Sub(now)
+}
+
+// idle indica se o contador voltou ao estado inicial e pode ser descartado
+func (c *Counter) idle(now time.Time, rules Rules) bool {
+	switch rules.Algorithm {
+	case limit_entity.TokenBucket:
+		return now.Sub(c.LastRefill) > idleDuration(rules)
+	case limit_entity.SlidingLog:
+		return len(c.Requests) == 0 || now.Sub(c.Requests[len(c.Requests)-1]) > rules.TTL
+	case limit_entity.SlidingWindowCounter:
+		return now.Sub(c.KeyCreatedAt) > idleDuration(rules)
+	default:
+		return now.Sub(c.KeyCreatedAt) > rules.TTL
+	}
+}
+
+// idleDuration é o tempo sem requisições até o contador da key não fazer mais diferença
+func idleDuration(rules Rules) time.Duration {
+	if rules.Algorithm == limit_entity.TokenBucket && rules.Limit > 0 {
+		// tempo para encher o bucket vazio
+		return time.Duration(bucketCapacity(rules) * float64(rules.TTL) / float64(rules.Limit))
+	}
+	if rules.Algorithm == limit_entity.SlidingWindowCounter {
+		// a janela anterior ainda pesa durante a atual
+		return 2 * rules.TTL
+	}
+	return rules.TTL
+}
+
+func bucketCapacity(rules Rules) float64 {
+	if rules.Burst > 0 {
+		return float64(rules.Burst)
+	}
+	return float64(rules.Limit)
 }
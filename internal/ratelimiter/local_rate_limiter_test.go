@@ -0,0 +1,596 @@
+package ratelimiter_test
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/Higor-ViniciusDev/posgo_raterlimite/internal/entity/limit_entity"
+	"github.com/Higor-ViniciusDev/posgo_raterlimite/internal/ratelimiter"
+)
+
+// send consulta o limiter e retorna true quando a requisição foi autorizada
+func send(t *testing.T, limiter ratelimiter.Limiter, key string, rules ratelimiter.Rules) bool {
+	t.Helper()
+	return decide(t, limiter, key, rules).Allowed
+}
+
+func decide(t *testing.T, limiter ratelimiter.Limiter, key string, rules ratelimiter.Rules) ratelimiter.Decision {
+	t.Helper()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	decision, err := limiter.Allow(ctx, key, rules)
+	if err != nil {
+		t.Fatalf("erro no limiter: %v", err)
+	}
+	return decision
+}
+
+func TestLimiters_FixedWindowDecision(t *testing.T) {
+	limiters := map[string]ratelimiter.Limiter{
+		"pool":   ratelimiter.NewRateLimiter(2, 10),
+		"direct": ratelimiter.NewLocalLimiter(0),
+	}
+
+	rules := ratelimiter.Rules{Limit: 3, TTL: time.Second, Penalty: 2 * time.Second}
+
+	for name, limiter := range limiters {
+		t.Run(name, func(t *testing.T) {
+			// a requisição que atinge o limit já é recusada
+			for i := int64(1); i < rules.Limit; i++ {
+				decision := decide(t, limiter, "decision", rules)
+				if !decision.Allowed || decision.Remaining != rules.Limit-1-i {
+					t.Fatalf("requisição %d: esperado liberada com %d restantes, recebido %+v", i, rules.Limit-1-i, decision)
+				}
+				if decision.ResetAt.IsZero() || time.Until(decision.ResetAt) > rules.TTL {
+					t.Fatalf("reset fora da janela: %v", decision.ResetAt)
+				}
+			}
+
+			decision := decide(t, limiter, "decision", rules)
+			if decision.Allowed || decision.Remaining != 0 {
+				t.Fatalf("requisição acima do limite deveria ser bloqueada, recebido %+v", decision)
+			}
+			// a penalidade é maior que a janela, o bloqueio vai até o fim dela
+			if wait := decision.RetryAfter(); wait < rules.Penalty-100*time.Millisecond || wait > rules.Penalty {
+				t.Fatalf("bloqueio deveria durar a penalidade, recebido %v", wait)
+			}
+		})
+	}
+}
+
+func TestRateLimiter_TokenBucketAllowsBurstAndRefills(t *testing.T) {
+	rl := ratelimiter.NewRateLimiter(1, 10)
+
+	// 10 tokens por segundo com rajada de 5
+	rules := ratelimiter.Rules{Limit: 10, TTL: time.Second, Algorithm: limit_entity.TokenBucket, Burst: 5}
+
+	for i := 0; i < 5; i++ {
+		if !send(t, rl, "burst", rules) {
+			t.Fatalf("requisição %d da rajada deveria passar", i+1)
+		}
+	}
+
+	if send(t, rl, "burst", rules) {
+		t.Fatal("requisição acima da capacidade do bucket deveria ser bloqueada")
+	}
+
+	// 100ms reabastece 1 token
+	time.Sleep(120 * time.Millisecond)
+
+	if !send(t, rl, "burst", rules) {
+		t.Fatal("requisição depois do reabastecimento deveria passar")
+	}
+	if send(t, rl, "burst", rules) {
+		t.Fatal("apenas um token deveria ter sido reabastecido")
+	}
+}
+
+func TestRateLimiter_TokenBucketPenalty(t *testing.T) {
+	rl := ratelimiter.NewRateLimiter(1, 10)
+
+	rules := ratelimiter.Rules{Limit: 10, TTL: time.Second, Penalty: 500 * time.Millisecond, Algorithm: limit_entity.TokenBucket, Burst: 2}
+
+	send(t, rl, "penalty", rules)
+	send(t, rl, "penalty", rules)
+
+	if send(t, rl, "penalty", rules) {
+		t.Fatal("bucket vazio deveria bloquear")
+	}
+
+	// Sem penalidade o bucket já teria token novo, mas a key continua bloqueada
+	time.Sleep(200 * time.Millisecond)
+	if send(t, rl, "penalty", rules) {
+		t.Fatal("key deveria continuar bloqueada pela penalidade")
+	}
+
+	time.Sleep(400 * time.Millisecond)
+	if !send(t, rl, "penalty", rules) {
+		t.Fatal("key deveria ser liberada ao fim da penalidade")
+	}
+}
+
+func TestRateLimiter_GCRARetryAfter(t *testing.T) {
+	rl := ratelimiter.NewRateLimiter(1, 10)
+
+	// intervalo de emissão de 100ms com tolerância de 1 requisição extra
+	rules := ratelimiter.Rules{Limit: 10, TTL: time.Second, Algorithm: limit_entity.GCRA, Burst: 2}
+
+	if !send(t, rl, "gcra", rules) || !send(t, rl, "gcra", rules) {
+		t.Fatal("rajada dentro da tolerância deveria passar")
+	}
+
+	decision := decide(t, rl, "gcra", rules)
+	if decision.Allowed {
+		t.Fatal("requisição acima da tolerância deveria ser bloqueada")
+	}
+	if decision.RetryAfter() <= 0 || decision.RetryAfter() > 100*time.Millisecond {
+		t.Fatalf("retry after esperado até 100ms, recebido %v", decision.RetryAfter())
+	}
+
+	time.Sleep(decision.RetryAfter())
+
+	if !send(t, rl, "gcra", rules) {
+		t.Fatal("requisição após o retry after deveria passar")
+	}
+}
+
+func TestRateLimiter_GCRAPenaltyIsNotExtended(t *testing.T) {
+	rl := ratelimiter.NewRateLimiter(1, 10)
+
+	rules := ratelimiter.Rules{Limit: 10, TTL: time.Second, Penalty: 300 * time.Millisecond, Algorithm: limit_entity.GCRA, Burst: 1}
+
+	send(t, rl, "gcra-penalty", rules)
+
+	first := decide(t, rl, "gcra-penalty", rules)
+	if first.Allowed || first.RetryAfter() < 250*time.Millisecond {
+		t.Fatalf("penalidade deveria bloquear por ~300ms, recebido %v", first.RetryAfter())
+	}
+
+	// novas tentativas durante a penalidade não aumentam o bloqueio
+	second := decide(t, rl, "gcra-penalty", rules)
+	if second.Allowed || second.BlockedUntil.After(first.BlockedUntil) {
+		t.Fatalf("penalidade não deveria ser estendida: %v -> %v", first.BlockedUntil, second.BlockedUntil)
+	}
+
+	time.Sleep(second.RetryAfter())
+
+	if !send(t, rl, "gcra-penalty", rules) {
+		t.Fatal("key deveria ser liberada ao fim da penalidade")
+	}
+}
+
+// burstAtBoundary envia o limite inteiro no fim da primeira janela e de novo logo após a virada,
+// retornando quantas requisições passaram na segunda rajada
+func burstAtBoundary(t *testing.T, limiter ratelimiter.Limiter, key string, rules ratelimiter.Rules) int {
+	t.Helper()
+
+	// primeira requisição abre a janela
+	if !send(t, limiter, key, rules) {
+		t.Fatal("primeira requisição deveria passar")
+	}
+
+	time.Sleep(rules.TTL * 85 / 100)
+	for i := int64(1); i < rules.Limit; i++ {
+		if !send(t, limiter, key, rules) {
+			t.Fatalf("requisição %d dentro do limite deveria passar", i+1)
+		}
+	}
+
+	// vira a janela
+	time.Sleep(rules.TTL * 20 / 100)
+
+	allowed := 0
+	for i := int64(0); i < rules.Limit; i++ {
+		if send(t, limiter, key, rules) {
+			allowed++
+		}
+	}
+	return allowed
+}
+
+func TestRateLimiter_SlidingLogCapsBurstAtBoundary(t *testing.T) {
+	rl := ratelimiter.NewRateLimiter(1, 10)
+
+	rules := ratelimiter.Rules{Limit: 5, TTL: 400 * time.Millisecond, Algorithm: limit_entity.SlidingLog}
+
+	// as 4 requisições do fim da primeira janela ainda estão no log, sobra espaço para 1
+	if allowed := burstAtBoundary(t, rl, "sliding-log", rules); allowed != 1 {
+		t.Fatalf("rajada na virada da janela deveria liberar 1 requisição, liberou %d", allowed)
+	}
+
+	decision := decide(t, rl, "sliding-log", rules)
+	if decision.Allowed || decision.RetryAfter() <= 0 || decision.RetryAfter() > rules.TTL {
+		t.Fatalf("retry after deveria apontar para a saída da requisição mais antiga, recebido %v", decision.RetryAfter())
+	}
+}
+
+func TestRateLimiter_SlidingWindowCounterCapsBurstAtBoundary(t *testing.T) {
+	rl := ratelimiter.NewRateLimiter(1, 10)
+
+	rules := ratelimiter.Rules{Limit: 5, TTL: 400 * time.Millisecond, Algorithm: limit_entity.SlidingWindowCounter}
+
+	// a janela anterior (5 requisições) ainda pesa ~95%, a estimativa não abre espaço
+	if allowed := burstAtBoundary(t, rl, "sliding-counter", rules); allowed > 1 {
+		t.Fatalf("rajada na virada da janela deveria ser limitada, liberou %d", allowed)
+	}
+
+	// uma janela inteira depois o peso da anterior já caiu
+	time.Sleep(rules.TTL)
+	if !send(t, rl, "sliding-counter", rules) {
+		t.Fatal("requisição após uma janela inteira deveria passar")
+	}
+}
+
+func TestLocalLimiter_ShardsKeepLimitPerKey(t *testing.T) {
+	rules := ratelimiter.Rules{Limit: 3, TTL: time.Second}
+
+	for _, shards := range []int{1, 16} {
+		limiter := ratelimiter.NewLocalLimiter(shards)
+
+		for k := 0; k < 50; k++ {
+			key := fmt.Sprintf("shard-key-%d", k)
+
+			allowed := 0
+			for i := 0; i < 5; i++ {
+				if send(t, limiter, key, rules) {
+					allowed++
+				}
+			}
+			if allowed != int(rules.Limit-1) {
+				t.Fatalf("%d shards: key %s deveria liberar %d, liberou %d", shards, key, rules.Limit-1, allowed)
+			}
+		}
+
+		limiter.Stop()
+	}
+}
+
+// BenchmarkLocalLimiter compara um único lock com os shards; rode com -cpu 1,4,8 para ver o ganho com mais núcleos
+func BenchmarkLocalLimiter(b *testing.B) {
+	keys := make([]string, 1024)
+	for i := range keys {
+		keys[i] = fmt.Sprintf("bench-key-%d", i)
+	}
+
+	// limite alto para nenhuma key ser bloqueada durante o benchmark
+	rules := ratelimiter.Rules{Limit: 1 << 62, TTL: time.Minute}
+
+	for _, shards := range []int{1, ratelimiter.DefaultShards()} {
+		b.Run(fmt.Sprintf("shards=%d", shards), func(b *testing.B) {
+			limiter := ratelimiter.NewLocalLimiter(shards)
+			defer limiter.Stop()
+
+			ctx := context.Background()
+			var next atomic.Uint64
+
+			b.ResetTimer()
+			b.RunParallel(func(pb *testing.PB) {
+				i := next.Add(1) * 7919
+				for pb.Next() {
+					if _, err := limiter.Allow(ctx, keys[i%uint64(len(keys))], rules); err != nil {
+						b.Fatal(err)
+					}
+					i++
+				}
+			})
+		})
+	}
+}
+
+// offend esgota a cota da key e devolve quanto tempo ela ficou bloqueada
+func offend(t *testing.T, limiter ratelimiter.Limiter, key string, rules ratelimiter.Rules) time.Duration {
+	t.Helper()
+
+	for {
+		decision := decide(t, limiter, key, rules)
+		if !decision.Allowed {
+			return decision.RetryAfter()
+		}
+	}
+}
+
+// escalatingPenalties confere os degraus da penalidade e o decaimento depois do lookback
+func escalatingPenalties(t *testing.T, limiter ratelimiter.Limiter) {
+	t.Helper()
+
+	steps := []time.Duration{150 * time.Millisecond, 400 * time.Millisecond}
+	rules := ratelimiter.Rules{
+		Limit:         1,
+		TTL:           100 * time.Millisecond,
+		Algorithm:     limit_entity.TokenBucket,
+		PenaltyPolicy: limit_entity.NewPenaltyPolicy(steps, 700*time.Millisecond),
+	}
+
+	expected := []time.Duration{steps[0], steps[1], steps[1]}
+	for i, step := range expected {
+		wait := offend(t, limiter, "offender", rules)
+		if wait < step-50*time.Millisecond || wait > step {
+			t.Fatalf("infração %d deveria bloquear por ~%v, bloqueou %v", i+1, step, wait)
+		}
+		time.Sleep(wait + 20*time.Millisecond)
+	}
+
+	// um lookback sem infração para cada uma das três zera o histórico
+	time.Sleep(3 * rules.PenaltyPolicy.Lookback)
+	if wait := offend(t, limiter, "offender", rules); wait > steps[0] {
+		t.Fatalf("depois do lookback a penalidade deveria voltar ao primeiro degrau, bloqueou %v", wait)
+	}
+}
+
+func TestLocalLimiter_EscalatingPenalties(t *testing.T) {
+	escalatingPenalties(t, ratelimiter.NewLocalLimiter(0))
+}
+
+// weightedCost confere que o custo consome a cota em unidades, em todos os algoritmos
+func weightedCost(t *testing.T, limiter ratelimiter.Limiter) {
+	t.Helper()
+
+	algorithms := []limit_entity.Algorithm{
+		limit_entity.FixedWindow,
+		limit_entity.TokenBucket,
+		limit_entity.GCRA,
+		limit_entity.SlidingLog,
+		limit_entity.SlidingWindowCounter,
+	}
+
+	for _, algorithm := range algorithms {
+		t.Run(string(algorithm), func(t *testing.T) {
+			rules := ratelimiter.Rules{Limit: 10, TTL: time.Second, Algorithm: algorithm, Cost: 4}
+			key := "cost:" + string(algorithm)
+
+			for i := 0; i < 2; i++ {
+				if !send(t, limiter, key, rules) {
+					t.Fatalf("requisição pesada %d deveria caber na cota", i+1)
+				}
+			}
+
+			decision := decide(t, limiter, key, rules)
+			if decision.Allowed || decision.RetryAfter() <= 0 {
+				t.Fatalf("requisição pesada acima da cota deveria ser bloqueada, recebido %+v", decision)
+			}
+
+			// a janela fixa conta a requisição recusada, não sobra cota para a mais barata
+			rules.Cost = 2
+			if algorithm == limit_entity.FixedWindow {
+				if send(t, limiter, key, rules) {
+					t.Fatal("a janela fixa deveria contar a requisição recusada")
+				}
+				return
+			}
+
+			// as 2 unidades que sobraram ainda atendem uma requisição mais barata
+			if decision := decide(t, limiter, key, rules); !decision.Allowed || decision.Remaining != 0 {
+				t.Fatalf("requisição de custo 2 deveria usar o resto da cota, recebido %+v", decision)
+			}
+		})
+	}
+}
+
+func TestLocalLimiter_WeightedCost(t *testing.T) {
+	weightedCost(t, ratelimiter.NewLocalLimiter(0))
+}
+
+// multipleWindows confere que todas as janelas são verificadas juntas, em todos os algoritmos: a decisão
+// informa a janela estourada e a requisição recusada por uma janela não consome a cota das outras
+func multipleWindows(t *testing.T, limiter ratelimiter.Limiter) {
+	t.Helper()
+
+	algorithms := []limit_entity.Algorithm{
+		limit_entity.FixedWindow,
+		limit_entity.TokenBucket,
+		limit_entity.GCRA,
+		limit_entity.SlidingLog,
+		limit_entity.SlidingWindowCounter,
+	}
+
+	for _, algorithm := range algorithms {
+		t.Run(string(algorithm), func(t *testing.T) {
+			ttl := 200 * time.Millisecond
+			rules := ratelimiter.Rules{
+				Limit:     2,
+				TTL:       ttl,
+				Algorithm: algorithm,
+				Windows:   []limit_entity.Window{{Limit: 3, TTL: time.Hour}},
+			}
+			// a janela fixa recusa a requisição que atinge o limit, um a mais admite a mesma quantidade
+			if algorithm == limit_entity.FixedWindow {
+				rules.Limit++
+				rules.Windows[0].Limit++
+			}
+			key := "windows:" + string(algorithm)
+
+			for i := 0; i < 2; i++ {
+				if !send(t, limiter, key, rules) {
+					t.Fatalf("requisição %d deveria caber nas duas janelas", i+1)
+				}
+			}
+
+			decision := decide(t, limiter, key, rules)
+			if decision.Allowed || decision.Window != ttl {
+				t.Fatalf("terceira requisição deveria estourar a janela de %v, recebido %+v", ttl, decision)
+			}
+
+			// a janela anterior do sliding window counter ainda pesa durante a atual
+			time.Sleep(2*ttl + 50*time.Millisecond)
+
+			decision = decide(t, limiter, key, rules)
+			if !decision.Allowed || decision.Remaining != 0 || decision.Window != time.Hour {
+				t.Fatalf("a requisição recusada não deveria contar na janela de 1h, recebido %+v", decision)
+			}
+
+			time.Sleep(2*ttl + 50*time.Millisecond)
+
+			decision = decide(t, limiter, key, rules)
+			if decision.Allowed || decision.Window != time.Hour || decision.Limit != rules.Windows[0].Limit {
+				t.Fatalf("a janela de 1h deveria bloquear com a cota esgotada, recebido %+v", decision)
+			}
+		})
+	}
+}
+
+func TestLocalLimiter_MultipleWindows(t *testing.T) {
+	multipleWindows(t, ratelimiter.NewLocalLimiter(0))
+}
+
+func decideAll(t *testing.T, limiter ratelimiter.Limiter, checks []ratelimiter.Check) (ratelimiter.Decision, int) {
+	t.Helper()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	decision, index, err := limiter.AllowAll(ctx, checks)
+	if err != nil {
+		t.Fatalf("erro no limiter: %v", err)
+	}
+	return decision, index
+}
+
+// allowAllChecks confere que as keys da cadeia são decididas juntas: a decisão é a da key mais perto de
+// esgotar e a recusada por uma key não consome a cota das outras, seja ela a primeira ou a última.
+// A janela fixa admite limit-1 requisições: o tolken admite 3 e o ip 2.
+func allowAllChecks(t *testing.T, limiter ratelimiter.Limiter) {
+	t.Helper()
+
+	tolken := ratelimiter.Check{Key: "chain:tolken", Rules: ratelimiter.Rules{Limit: 4, TTL: time.Minute}}
+	ip := ratelimiter.Check{Key: "chain:ip", Rules: ratelimiter.Rules{Limit: 3, TTL: time.Minute}}
+	checks := []ratelimiter.Check{tolken, ip}
+
+	for i := int64(1); i <= 2; i++ {
+		decision, index := decideAll(t, limiter, checks)
+		if !decision.Allowed || index != 1 || decision.Remaining != 2-i {
+			t.Fatalf("requisição %d: esperado liberada pelo ip com %d restantes, recebido %+v (check %d)", i, 2-i, decision, index)
+		}
+	}
+
+	decision, index := decideAll(t, limiter, checks)
+	if decision.Allowed || index != 1 {
+		t.Fatalf("o ip esgotado deveria recusar a cadeia, recebido %+v (check %d)", decision, index)
+	}
+
+	// a recusa do ip não contou no tolken
+	if d := decide(t, limiter, tolken.Key, tolken.Rules); !d.Allowed || d.Remaining != 0 {
+		t.Fatalf("o tolken deveria ter uma requisição restante, recebido %+v", d)
+	}
+
+	other := ratelimiter.Check{Key: "chain:other", Rules: ratelimiter.Rules{Limit: 2, TTL: time.Minute}}
+	decision, index = decideAll(t, limiter, []ratelimiter.Check{ip, other})
+	if decision.Allowed || index != 0 {
+		t.Fatalf("o ip esgotado deveria recusar a cadeia como primeira check, recebido %+v (check %d)", decision, index)
+	}
+	if !send(t, limiter, other.Key, other.Rules) {
+		t.Fatal("a recusa do ip não deveria consumir a cota da outra key")
+	}
+}
+
+func TestLimiters_AllowAll(t *testing.T) {
+	limiters := map[string]ratelimiter.Limiter{
+		"pool":   ratelimiter.NewRateLimiter(2, 10),
+		"direct": ratelimiter.NewLocalLimiter(0),
+		// um único shard: as keys da cadeia disputam o mesmo lock
+		"single_shard": ratelimiter.NewLocalLimiter(1),
+	}
+
+	for name, limiter := range limiters {
+		t.Run(name, func(t *testing.T) {
+			allowAllChecks(t, limiter)
+		})
+	}
+}
+
+// fixedWindowRejectsAtLimit confere a regra original da janela fixa: a requisição que leva o contador
+// ao limit já é recusada, então a janela admite Limit-1 requisições, e a recusada também conta
+func fixedWindowRejectsAtLimit(t *testing.T, limiter ratelimiter.Limiter) {
+	t.Helper()
+
+	for _, limit := range []int64{1, 2, 5} {
+		rules := ratelimiter.Rules{Limit: limit, TTL: time.Minute, Algorithm: limit_entity.FixedWindow}
+		key := fmt.Sprintf("rejects:%d", limit)
+
+		for i := int64(1); i < limit; i++ {
+			if !send(t, limiter, key, rules) {
+				t.Fatalf("limite %d: requisição %d deveria passar", limit, i)
+			}
+		}
+		if send(t, limiter, key, rules) {
+			t.Fatalf("limite %d: requisição %d deveria ser recusada", limit, limit)
+		}
+	}
+
+	// com custo, a requisição que atinge o limit é recusada e consome a cota mesmo assim
+	rules := ratelimiter.Rules{Limit: 5, TTL: time.Minute, Algorithm: limit_entity.FixedWindow, Cost: 3}
+	if !send(t, limiter, "rejects:cost", rules) || send(t, limiter, "rejects:cost", rules) {
+		t.Fatal("custo 3 com limite 5 deveria passar uma vez e recusar a seguinte")
+	}
+	rules.Cost = 1
+	if send(t, limiter, "rejects:cost", rules) {
+		t.Fatal("a requisição recusada deveria ter consumido a cota da janela")
+	}
+}
+
+func TestLimiters_FixedWindowRejectsAtLimit(t *testing.T) {
+	limiters := map[string]ratelimiter.Limiter{
+		"pool":   ratelimiter.NewRateLimiter(2, 10),
+		"direct": ratelimiter.NewLocalLimiter(0),
+	}
+
+	for name, limiter := range limiters {
+		t.Run(name, func(t *testing.T) {
+			fixedWindowRejectsAtLimit(t, limiter)
+		})
+	}
+}
+
+func TestRateLimiter_StopWhileSending(t *testing.T) {
+	rl := ratelimiter.NewRateLimiter(2, 1)
+	rules := ratelimiter.Rules{Limit: 1000, TTL: time.Minute}
+
+	// Allow chamado durante e depois do Stop recebe a decisão ou o erro de shutdown, nunca um panic
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 200; j++ {
+				ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+				_, err := rl.Allow(ctx, "stop", rules)
+				cancel()
+				if err != nil && err.Message != "server shutdown" {
+					t.Errorf("erro inesperado no shutdown: %v", err)
+					return
+				}
+			}
+		}()
+	}
+
+	time.Sleep(time.Millisecond)
+	rl.Stop()
+	wg.Wait()
+}
+
+func TestLocalLimiter_RestoredKeyKeepsNewCounter(t *testing.T) {
+	limiter := ratelimiter.NewLocalLimiter(1)
+	defer limiter.Stop()
+
+	// a key nova é desfeita pela recusa da outra, a rotina de expiração dela fica com a janela de 100ms
+	short := ratelimiter.Check{Key: "restored", Rules: ratelimiter.Rules{Limit: 5, TTL: 100 * time.Millisecond}}
+	blocker := ratelimiter.Check{Key: "restored:blocker", Rules: ratelimiter.Rules{Limit: 1, TTL: time.Minute}}
+	if decision, _ := decideAll(t, limiter, []ratelimiter.Check{short, blocker}); decision.Allowed {
+		t.Fatal("a key com limite 1 deveria recusar a cadeia")
+	}
+
+	// recriada com uma janela longa, a rotina antiga não pode apagar o contador novo
+	rules := ratelimiter.Rules{Limit: 2, TTL: time.Hour}
+	if !send(t, limiter, short.Key, rules) {
+		t.Fatal("primeira requisição da key recriada deveria passar")
+	}
+	time.Sleep(1300 * time.Millisecond)
+	if send(t, limiter, short.Key, rules) {
+		t.Fatal("o contador recriado foi expirado pela rotina da key desfeita")
+	}
+}
@@ -0,0 +1,516 @@
+package ratelimiter
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Higor-ViniciusDev/posgo_raterlimite/configuration/logger"
+	"github.com/Higor-ViniciusDev/posgo_raterlimite/internal/entity/limit_entity"
+	"github.com/Higor-ViniciusDev/posgo_raterlimite/internal/internal_error"
+	"github.com/redis/go-redis/v9"
+)
+
+// Prefixo das keys do rate limiter no redis, separa dos tolkens e das request infos
+const redisKeyPrefix = "ratelimit:"
+
+// redisStore decide as mensagens com scripts Lua, a leitura e a escrita do estado da key
+// acontecem de forma atômica no redis e todas as réplicas compartilham o mesmo limite.
+type redisStore struct {
+	client *redis.Client
+}
+
+// NewRedisRateLimiter cria um RateLimiter com o mesmo contrato do local (InputChan/ReplyChan),
+// mas com os contadores no redis.
+func NewRedisRateLimiter(client *redis.Client, workers int, queueSize int) *RateLimiter {
+	return newRateLimiter(&redisStore{client: client}, workers, queueSize)
+}
+
+// O estado continua no redis para as outras réplicas
+func (s *redisStore) stop() {}
+
+func (s *redisStore) allow(ctx context.Context, key string, rules Rules) (Decision, *internal_error.InternalError) {
+	decision, _, err := s.allowAll(ctx, []Check{{Key: key, Rules: rules}})
+	return decision, err
+}
+
+// allowAll decide todas as keys em uma única chamada do script, de forma atômica no redis: as janelas de todas
+// são verificadas antes de consumir qualquer cota, então nenhuma outra réplica age entre a verificação e o consumo.
+// A primeira recusada encerra a cadeia guardando apenas o próprio bloqueio.
+func (s *redisStore) allowAll(ctx context.Context, checks []Check) (Decision, int, *internal_error.InternalError) {
+	if len(checks) == 0 {
+		return Decision{}, 0, errEmptyChecks()
+	}
+
+	now := time.Now()
+	args := []interface{}{
+		formatMillis(float64(now.UnixNano()) / float64(time.Millisecond)),
+		strconv.FormatInt(now.UnixNano(), 36),
+		len(checks),
+	}
+
+	// as infrações da penalidade escalonada ficam em uma key própria, comum a todos os algoritmos;
+	// cada janela tem a key do estado e a do bloqueio (usada pelo sliding log, que guarda o log em um sorted set)
+	var keys []string
+	for _, check := range checks {
+		rules := check.Rules
+		redisKey := redisKeyPrefix + check.Key
+		windows := rules.windowRules()
+
+		keys = append(keys, redisKey, redisKey+":offenses", redisKey+":blocked")
+		extras := make([]string, 0, len(windows)-1)
+		for i, w := range windows[1:] {
+			stateKey := redisKeyPrefix + windowKey(check.Key, i+1, w)
+			keys = append(keys, stateKey, stateKey+":blocked")
+			extras = append(extras, strconv.FormatInt(w.Limit, 10)+":"+formatMillis(durationMillis(w.TTL)))
+		}
+
+		args = append(args,
+			scriptAlgorithm(rules.Algorithm),
+			rules.Limit,
+			formatMillis(durationMillis(rules.TTL)),
+			formatMillis(durationMillis(rules.Penalty)),
+			int64(bucketCapacity(rules)),
+			formatMillis(durationMillis(rules.PenaltyPolicy.Lookback)),
+			formatSteps(rules.PenaltyPolicy.Steps),
+			rules.cost(),
+			strings.Join(extras, ","),
+		)
+	}
+
+	result, err := windowScript.Run(ctx, s.client, keys, args...).Int64Slice()
+	if err != nil {
+		logger.Error("error run rate limit script redis", err)
+		return Decision{}, 0, internal_error.NewInternalServerError("error rate limiter redis")
+	}
+
+	// {liberada, espera, restante, reset, janela, check}, tempos em ms a partir de agora
+	index := int(result[5])
+	check := checks[index]
+	window := check.Rules.windowRules()[result[4]]
+	decision := Decision{
+		Allowed:   result[0] == 1,
+		Limit:     window.Limit,
+		Remaining: result[2],
+		ResetAt:   now.Add(time.Duration(result[3]) * time.Millisecond),
+		Window:    window.TTL,
+	}
+	if check.Rules.Algorithm == limit_entity.TokenBucket || check.Rules.Algorithm == limit_entity.GCRA {
+		decision.Limit = int64(bucketCapacity(window))
+	}
+
+	if !decision.Allowed {
+		decision.BlockedUntil = now.Add(time.Duration(result[1]) * time.Millisecond)
+		logger.Info(fmt.Sprintf("Rate limit exceeded for key: %s", check.Key))
+	}
+
+	return decision, index, nil
+}
+
+// scriptAlgorithm é o nome da função do algoritmo no script, sem algoritmo vale a janela fixa
+func scriptAlgorithm(algorithm limit_entity.Algorithm) string {
+	switch algorithm {
+	case limit_entity.TokenBucket, limit_entity.GCRA, limit_entity.SlidingLog, limit_entity.SlidingWindowCounter:
+		return string(algorithm)
+	default:
+		return string(limit_entity.FixedWindow)
+	}
+}
+
+// formatSteps junta os degraus da penalidade em ms separados por vírgula
+func formatSteps(steps []time.Duration) string {
+	values := make([]string, len(steps))
+	for i, step := range steps {
+		values[i] = formatMillis(durationMillis(step))
+	}
+	return strings.Join(values, ",")
+}
+
+func durationMillis(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
+
+// Os scripts trabalham em milissegundos com casas decimais; formatar como texto evita notação
+// científica na conversão dos números do Lua.
+func formatMillis(value float64) string {
+	return strconv.FormatFloat(value, 'f', 3, 64)
+}
+
+// Funções comuns aos algoritmos. ARGV[1] é agora (ms), ARGV[2] diferencia as entradas do sliding log e
+// ARGV[3] é a quantidade de checks. As regras da check em uso (limite, ttl, penalidade, capacidade da rajada,
+// lookback, degraus da penalidade escalonada e custo) são trocadas por use antes de rodar cada janela.
+// Retorno dos algoritmos: {liberada (1/0), espera, restante, reset}, com espera e reset em ms a partir de agora.
+const luaHeader = `
+local now = tonumber(ARGV[1])
+local uid = ARGV[2]
+
+local limit, ttl, penalty, capacity, lookback, cost, offensesKey
+local steps, penalized, maxPenalty
+
+-- use troca as regras lidas pelas funções dos algoritmos pelas da check
+local function use(check)
+	limit = check.limit
+	ttl = check.ttl
+	penalty = check.penalty
+	capacity = check.capacity
+	lookback = check.lookback
+	cost = check.cost
+	offensesKey = check.offenses
+	steps = check.steps
+
+	penalized = penalty > 0 or #steps > 0
+	maxPenalty = penalty
+	if #steps > 0 then
+		maxPenalty = steps[#steps]
+	end
+end
+
+local function num(value)
+	return string.format('%.3f', value)
+end
+
+local function expire(key, value)
+	redis.call('PEXPIRE', key, string.format('%d', math.ceil(value) + 1000))
+end
+
+-- Mesma regra do nextPenalty: registra a infração e devolve a penalidade dela
+local function nextPenalty()
+	if #steps == 0 then
+		return penalty
+	end
+
+	local state = redis.call('HMGET', offensesKey, 'offenses', 'last_offense')
+	local offenses = tonumber(state[1]) or 0
+	local last = tonumber(state[2]) or now
+	if lookback > 0 then
+		offenses = math.max(0, offenses - math.floor((now - last) / lookback))
+	else
+		offenses = 0
+	end
+	offenses = offenses + 1
+
+	redis.call('HMSET', offensesKey, 'offenses', offenses, 'last_offense', num(now))
+	expire(offensesKey, lookback * offenses)
+	return steps[math.min(offenses, #steps)]
+end
+
+local function result(allowed, wait, remaining, reset)
+	local flag = 0
+	if allowed then
+		flag = 1
+		wait = 0
+	else
+		remaining = 0
+	end
+	reset = math.max(reset, wait)
+	return {flag, math.ceil(math.max(wait, 0)), math.floor(math.max(remaining, 0)), math.ceil(math.max(reset, 0))}
+end
+
+-- cada algoritmo registra aqui a função run(key, blockedKey, limit, ttl, capacity, commit),
+-- que sem commit só grava o estado quando recusa a requisição
+local algorithms = {}
+`
+
+// luaFooter monta as checks a partir de ARGV[4]: nome do algoritmo, limite, ttl, penalidade, capacidade,
+// lookback, degraus, custo e janelas extras ("limite:ttl" em ms separados por vírgula). As keys de cada check são
+// o estado, as infrações e o bloqueio da janela principal, seguidas do estado e do bloqueio de cada janela extra.
+// Todas as janelas de todas as checks são verificadas antes de consumir qualquer cota: a primeira estourada
+// recusa a requisição e só ela guarda o bloqueio. O retorno ganha o índice da janela e o da check da decisão.
+const luaFooter = `
+local checks = {}
+local keyIndex = 1
+local argIndex = 4
+for index = 1, tonumber(ARGV[3]) do
+	local check = {
+		algorithm = ARGV[argIndex],
+		limit = tonumber(ARGV[argIndex + 1]),
+		ttl = tonumber(ARGV[argIndex + 2]),
+		penalty = tonumber(ARGV[argIndex + 3]),
+		capacity = tonumber(ARGV[argIndex + 4]),
+		lookback = tonumber(ARGV[argIndex + 5]),
+		cost = math.max(tonumber(ARGV[argIndex + 7]), 1),
+		offenses = KEYS[keyIndex + 1],
+		steps = {},
+	}
+	for step in string.gmatch(ARGV[argIndex + 6], '[^,]+') do
+		check.steps[#check.steps + 1] = tonumber(step)
+	end
+
+	check.windows = {{KEYS[keyIndex], KEYS[keyIndex + 2], check.limit, check.ttl, check.capacity}}
+	keyIndex = keyIndex + 3
+	for windowLimit, windowTTL in string.gmatch(ARGV[argIndex + 8], '([^:,]+):([^,]+)') do
+		check.windows[#check.windows + 1] = {KEYS[keyIndex], KEYS[keyIndex + 1], tonumber(windowLimit), tonumber(windowTTL), tonumber(windowLimit)}
+		keyIndex = keyIndex + 2
+	end
+
+	argIndex = argIndex + 9
+	checks[index] = check
+end
+
+local function runWindow(checkIndex, windowIndex, commit)
+	local check = checks[checkIndex]
+	use(check)
+	local w = check.windows[windowIndex]
+	local res = algorithms[check.algorithm](w[1], w[2], w[3], w[4], w[5], commit)
+	res[5] = windowIndex - 1
+	res[6] = checkIndex - 1
+	return res
+end
+
+if #checks == 1 and #checks[1].windows == 1 then
+	return runWindow(1, 1, true)
+end
+
+for checkIndex = 1, #checks do
+	for windowIndex = 1, #checks[checkIndex].windows do
+		local res = runWindow(checkIndex, windowIndex, false)
+		if res[1] == 0 then
+			return res
+		end
+	end
+end
+
+local best
+for checkIndex = 1, #checks do
+	for windowIndex = 1, #checks[checkIndex].windows do
+		local res = runWindow(checkIndex, windowIndex, true)
+		if best == nil or res[3] < best[3] then
+			best = res
+		end
+	end
+end
+return best
+`
+
+// windowScript decide todas as checks da requisição, cada uma com o próprio algoritmo
+var windowScript = redis.NewScript(luaHeader + fixedWindowLua + tokenBucketLua + gcraLua + slidingLogLua + slidingWindowLua + luaFooter)
+
+// Mesma regra do incrementFixedWindow: penalidade bloqueia a key e o contador zera ao fim do TTL
+const fixedWindowLua = `
+algorithms['fixed_window'] = function(key, blockedKey, limit, ttl, capacity, commit)
+	local state = redis.call('HMGET', key, 'count', 'blocked_until', 'created_at')
+	local count = tonumber(state[1]) or 0
+	local blocked = tonumber(state[2]) or 0
+	local created = tonumber(state[3]) or 0
+
+	if blocked > 0 and now < blocked then
+		return result(false, math.max(created + ttl - now, blocked - now), 0, created + ttl - now)
+	end
+
+	local penaltyExpired = blocked == 0 or now > blocked
+	local ttlExpired = now - created > ttl
+	if penaltyExpired and ttlExpired then
+		count = 0
+		blocked = 0
+		created = now
+	elseif penaltyExpired then
+		blocked = 0
+	end
+
+	-- mesma regra do incrementFixedWindow: conta a requisição e recusa ao atingir o limit
+	count = count + cost
+	local allowed = count < limit
+	if not allowed and blocked == 0 then
+		blocked = now + nextPenalty()
+	end
+
+	if commit or not allowed then
+		redis.call('HMSET', key, 'count', count, 'blocked_until', num(blocked), 'created_at', num(created))
+		expire(key, math.max(ttl, maxPenalty))
+	end
+
+	return result(allowed, math.max(created + ttl - now, blocked - now), limit - count - 1, created + ttl - now)
+end
+`
+
+// Mesma regra do takeToken: reabastece limit tokens por ttl até a capacidade
+const tokenBucketLua = `
+algorithms['token_bucket'] = function(key, blockedKey, limit, ttl, capacity, commit)
+	local state = redis.call('HMGET', key, 'tokens', 'last_refill', 'blocked_until')
+	local tokens = tonumber(state[1])
+	local last = tonumber(state[2])
+	local blocked = tonumber(state[3]) or 0
+
+	local function refill(current)
+		if limit <= 0 then
+			return 0
+		end
+		return (capacity - current) * ttl / limit
+	end
+
+	if blocked > 0 and now < blocked then
+		return result(false, blocked - now, 0, blocked - now)
+	end
+
+	if last == nil or ttl <= 0 then
+		tokens = capacity
+	else
+		tokens = math.min(capacity, tokens + (now - last) * limit / ttl)
+	end
+
+	local allowed = tokens >= cost
+	if allowed then
+		tokens = tokens - cost
+	elseif penalized then
+		blocked = now + nextPenalty()
+	end
+
+	if commit or not allowed then
+		redis.call('HMSET', key, 'tokens', num(tokens), 'last_refill', num(now), 'blocked_until', num(blocked))
+		expire(key, math.max(refill(0), maxPenalty))
+	end
+
+	local wait = 0
+	if limit > 0 then
+		wait = (cost - tokens) * ttl / limit
+	end
+	return result(allowed, math.max(wait, blocked - now), tokens, refill(tokens))
+end
+`
+
+// Mesma regra do allowGCRA: guarda apenas o TAT da key
+const gcraLua = `
+algorithms['gcra'] = function(key, blockedKey, limit, ttl, capacity, commit)
+	if limit <= 0 or ttl <= 0 then
+		return result(true, 0, 0, 0)
+	end
+
+	local emission = ttl / limit
+	local tolerance = emission * (capacity - 1)
+
+	local tat = tonumber(redis.call('GET', key)) or now
+	if tat < now then
+		tat = now
+	end
+
+	local increment = emission * cost
+	local allowAt = tat + increment - emission - tolerance
+	if now < allowAt then
+		if penalized and allowAt - now <= emission then
+			local applied = nextPenalty()
+			tat = now + applied + tolerance
+			allowAt = now + applied
+			redis.call('SET', key, num(tat))
+			expire(key, tat - now)
+		end
+		return result(false, allowAt - now, 0, tat - now)
+	end
+
+	tat = tat + increment
+	if commit then
+		redis.call('SET', key, num(tat))
+		expire(key, tat - now)
+	end
+	return result(true, 0, math.floor((now + tolerance - tat) / emission) + 1, tat - now)
+end
+`
+
+// Mesma regra do appendSlidingLog: sorted set com o horário de cada requisição aceita,
+// o bloqueio da penalidade fica na blockedKey
+const slidingLogLua = `
+algorithms['sliding_log'] = function(key, blockedKey, limit, ttl, capacity, commit)
+	local function newest()
+		local last = redis.call('ZRANGE', key, -1, -1, 'WITHSCORES')
+		if last[2] then
+			return tonumber(last[2]) + ttl - now
+		end
+		return 0
+	end
+
+	local blocked = tonumber(redis.call('GET', blockedKey)) or 0
+	if blocked > 0 and now < blocked then
+		return result(false, blocked - now, 0, newest())
+	end
+
+	redis.call('ZREMRANGEBYSCORE', key, '-inf', num(now - ttl))
+
+	local count = redis.call('ZCARD', key)
+	if count + cost > limit then
+		local wait = 0
+		local leaving = math.min(count - (limit - cost), count)
+		if leaving > 0 then
+			local oldest = redis.call('ZRANGE', key, leaving - 1, leaving - 1, 'WITHSCORES')
+			if oldest[2] then
+				wait = tonumber(oldest[2]) + ttl - now
+			end
+		end
+		if penalized then
+			local applied = nextPenalty()
+			redis.call('SET', blockedKey, num(now + applied))
+			expire(blockedKey, applied)
+			wait = math.max(wait, applied)
+		end
+		return result(false, wait, 0, newest())
+	end
+
+	if commit then
+		for unit = 1, cost do
+			redis.call('ZADD', key, num(now), num(now) .. '-' .. uid .. '-' .. unit)
+		end
+		expire(key, ttl)
+	end
+	return result(true, 0, limit - count - cost, ttl)
+end
+`
+
+// Mesma regra do incrementSlidingWindow: pondera a janela anterior pela fração sobreposta
+const slidingWindowLua = `
+algorithms['sliding_window_counter'] = function(key, blockedKey, limit, ttl, capacity, commit)
+	local state = redis.call('HMGET', key, 'count', 'previous', 'window_start', 'blocked_until')
+	local count = tonumber(state[1]) or 0
+	local previous = tonumber(state[2]) or 0
+	local start = tonumber(state[3])
+	local blocked = tonumber(state[4]) or 0
+
+	if blocked > 0 and now < blocked then
+		return result(false, blocked - now, 0, (start or now) + ttl - now)
+	end
+
+	if start == nil or ttl <= 0 then
+		start = now
+	else
+		local elapsed = math.floor((now - start) / ttl)
+		if elapsed >= 1 then
+			if elapsed == 1 then
+				previous = count
+			else
+				previous = 0
+			end
+			count = 0
+			start = start + elapsed * ttl
+		end
+	end
+
+	local function estimate()
+		if ttl <= 0 then
+			return count
+		end
+		return previous * (1 - (now - start) / ttl) + count
+	end
+
+	local allowed = estimate() + cost <= limit
+	if allowed then
+		count = count + cost
+	elseif penalized then
+		blocked = now + nextPenalty()
+	end
+
+	if commit or not allowed then
+		redis.call('HMSET', key, 'count', count, 'previous', previous, 'window_start', num(start), 'blocked_until', num(blocked))
+		expire(key, math.max(2 * ttl, maxPenalty))
+	end
+
+	local wait = 0
+	local free = limit - cost
+	if count <= free and previous > 0 then
+		wait = start + (1 - (free - count) / previous) * ttl - now
+	elseif count > 0 then
+		wait = start + ttl + math.max(0, 1 - free / count) * ttl - now
+	else
+		wait = start + ttl - now
+	end
+	return result(allowed, math.max(wait, blocked - now), limit - estimate(), start + ttl - now)
+end
+`
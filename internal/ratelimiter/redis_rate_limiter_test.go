@@ -0,0 +1,199 @@
+package ratelimiter_test
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/Higor-ViniciusDev/posgo_raterlimite/internal/entity/limit_entity"
+	"github.com/Higor-ViniciusDev/posgo_raterlimite/internal/ratelimiter"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+func newRedisClient(t *testing.T) *redis.Client {
+	t.Helper()
+
+	mr, err := miniredis.Run()
+	if err != nil {
+		t.Fatalf("erro ao iniciar miniredis: %v", err)
+	}
+	t.Cleanup(mr.Close)
+
+	return redis.NewClient(&redis.Options{Addr: mr.Addr()})
+}
+
+func TestRedisRateLimiter_FixedWindowMatchesLocal(t *testing.T) {
+	client := newRedisClient(t)
+
+	rules := ratelimiter.Rules{Limit: 5, TTL: time.Second, Penalty: 300 * time.Millisecond, Algorithm: limit_entity.FixedWindow}
+
+	local := ratelimiter.NewRateLimiter(1, 10)
+	distributed := ratelimiter.NewRedisRateLimiter(client, 1, 10)
+
+	for i := 0; i < 8; i++ {
+		localDecision := decide(t, local, "same", rules)
+		redisDecision := decide(t, distributed, "same", rules)
+
+		if localDecision.Allowed != redisDecision.Allowed || localDecision.Remaining != redisDecision.Remaining {
+			t.Fatalf("requisição %d: local %+v, redis %+v", i+1, localDecision, redisDecision)
+		}
+	}
+
+	// fim da penalidade e da janela libera as duas
+	time.Sleep(time.Second + 100*time.Millisecond)
+	if !send(t, local, "same", rules) || !send(t, distributed, "same", rules) {
+		t.Fatal("key deveria ser liberada depois da penalidade e da janela")
+	}
+}
+
+func TestRedisRateLimiter_SharesLimitBetweenReplicas(t *testing.T) {
+	client := newRedisClient(t)
+
+	rules := ratelimiter.Rules{Limit: 6, TTL: time.Second, Algorithm: limit_entity.SlidingLog}
+
+	replicas := []ratelimiter.Limiter{
+		ratelimiter.NewRedisRateLimiter(client, 2, 10),
+		ratelimiter.NewRedisRateLimiter(client, 2, 10),
+		ratelimiter.NewRedisLimiter(client),
+	}
+
+	allowed := 0
+	for i := 0; i < 12; i++ {
+		if send(t, replicas[i%len(replicas)], "shared", rules) {
+			allowed++
+		}
+	}
+
+	if allowed != 6 {
+		t.Fatalf("o limite deveria valer para todas as réplicas juntas, liberou %d de 12", allowed)
+	}
+}
+
+func TestRedisRateLimiter_Algorithms(t *testing.T) {
+	client := newRedisClient(t)
+	rl := ratelimiter.NewRedisRateLimiter(client, 1, 10)
+
+	algorithms := []limit_entity.Algorithm{
+		limit_entity.TokenBucket,
+		limit_entity.GCRA,
+		limit_entity.SlidingLog,
+		limit_entity.SlidingWindowCounter,
+	}
+
+	for _, algorithm := range algorithms {
+		t.Run(string(algorithm), func(t *testing.T) {
+			rules := ratelimiter.Rules{Limit: 4, TTL: 400 * time.Millisecond, Algorithm: algorithm}
+			key := "algorithm:" + string(algorithm)
+
+			for i := 0; i < 4; i++ {
+				if !send(t, rl, key, rules) {
+					t.Fatalf("requisição %d dentro do limite deveria passar", i+1)
+				}
+			}
+
+			decision := decide(t, rl, key, rules)
+			if decision.Allowed {
+				t.Fatal("requisição acima do limite deveria ser bloqueada")
+			}
+			if decision.RetryAfter() <= 0 || decision.RetryAfter() > 2*rules.TTL {
+				t.Fatalf("retry after fora do esperado: %v", decision.RetryAfter())
+			}
+
+			time.Sleep(decision.RetryAfter() + 20*time.Millisecond)
+			if !send(t, rl, key, rules) {
+				t.Fatal("requisição após o retry after deveria passar")
+			}
+		})
+	}
+}
+
+func TestRedisRateLimiter_SlidingWindowCounterCapsBurstAtBoundary(t *testing.T) {
+	client := newRedisClient(t)
+	rl := ratelimiter.NewRedisRateLimiter(client, 1, 10)
+
+	rules := ratelimiter.Rules{Limit: 5, TTL: 400 * time.Millisecond, Algorithm: limit_entity.SlidingWindowCounter}
+
+	if allowed := burstAtBoundary(t, rl, "sliding-counter", rules); allowed > 1 {
+		t.Fatalf("rajada na virada da janela deveria ser limitada, liberou %d", allowed)
+	}
+}
+
+func TestRedisRateLimiter_EscalatingPenalties(t *testing.T) {
+	escalatingPenalties(t, ratelimiter.NewRedisLimiter(newRedisClient(t)))
+}
+
+func TestRedisRateLimiter_WeightedCost(t *testing.T) {
+	weightedCost(t, ratelimiter.NewRedisLimiter(newRedisClient(t)))
+}
+
+func TestRedisRateLimiter_MultipleWindows(t *testing.T) {
+	multipleWindows(t, ratelimiter.NewRedisLimiter(newRedisClient(t)))
+}
+
+func TestRedisRateLimiter_AllowAll(t *testing.T) {
+	allowAllChecks(t, ratelimiter.NewRedisLimiter(newRedisClient(t)))
+}
+
+func TestRedisRateLimiter_FixedWindowRejectsAtLimit(t *testing.T) {
+	fixedWindowRejectsAtLimit(t, ratelimiter.NewRedisLimiter(newRedisClient(t)))
+}
+
+// commandCounter conta os comandos que o redis executou (o EVALSHA recusado com NOSCRIPT, seguido do EVAL, não conta)
+type commandCounter struct {
+	commands atomic.Int64
+}
+
+func (c *commandCounter) DialHook(next redis.DialHook) redis.DialHook {
+	return next
+}
+
+func (c *commandCounter) ProcessHook(next redis.ProcessHook) redis.ProcessHook {
+	return func(ctx context.Context, cmd redis.Cmder) error {
+		err := next(ctx, cmd)
+		if err == nil || err == redis.Nil {
+			c.commands.Add(1)
+		}
+		return err
+	}
+}
+
+func (c *commandCounter) ProcessPipelineHook(next redis.ProcessPipelineHook) redis.ProcessPipelineHook {
+	return func(ctx context.Context, cmds []redis.Cmder) error {
+		err := next(ctx, cmds)
+		c.commands.Add(int64(len(cmds)))
+		return err
+	}
+}
+
+func TestRedisRateLimiter_AllowAllSingleScript(t *testing.T) {
+	client := newRedisClient(t)
+	counter := &commandCounter{}
+	client.AddHook(counter)
+	limiter := ratelimiter.NewRedisLimiter(client)
+
+	// cada key da cadeia com o próprio algoritmo e a do ip com uma janela extra
+	checks := []ratelimiter.Check{
+		{Key: "single:tolken", Rules: ratelimiter.Rules{Limit: 5, TTL: time.Minute, Algorithm: limit_entity.TokenBucket}},
+		{Key: "single:ip", Rules: ratelimiter.Rules{Limit: 3, TTL: time.Minute, Algorithm: limit_entity.SlidingLog, Windows: []limit_entity.Window{{Limit: 2, TTL: time.Hour}}}},
+	}
+
+	decideAll(t, limiter, checks)
+	before := counter.commands.Load()
+	decision, index := decideAll(t, limiter, checks)
+	if commands := counter.commands.Load() - before; commands != 1 {
+		t.Fatalf("a cadeia inteira deveria ser decidida em uma chamada ao redis, recebidas %d", commands)
+	}
+	if !decision.Allowed || index != 1 || decision.Remaining != 0 {
+		t.Fatalf("esperado liberada pela janela extra do ip, recebido %+v (check %d)", decision, index)
+	}
+
+	// a janela extra do ip recusa a cadeia sem consumir o bucket do tolken
+	if decision, index = decideAll(t, limiter, checks); decision.Allowed || index != 1 {
+		t.Fatalf("o ip esgotado deveria recusar a cadeia, recebido %+v (check %d)", decision, index)
+	}
+	if d := decide(t, limiter, checks[0].Key, checks[0].Rules); !d.Allowed || d.Remaining != 2 {
+		t.Fatalf("o tolken deveria ter consumido só as duas liberadas, recebido %+v", d)
+	}
+}
@@ -0,0 +1,151 @@
+package access_list_usecase
+
+import (
+	"context"
+	"net/netip"
+	"sort"
+	"sync/atomic"
+	"time"
+
+	"github.com/Higor-ViniciusDev/posgo_raterlimite/configuration/logger"
+	"github.com/Higor-ViniciusDev/posgo_raterlimite/internal/entity/access_list_entity"
+	"github.com/Higor-ViniciusDev/posgo_raterlimite/internal/internal_error"
+)
+
+// Intervalo padrão para reler as listas do Redis, é o atraso máximo para as outras réplicas verem uma alteração
+const DefaultRefreshInterval = 5 * time.Second
+
+// AccessListUsecase mantém as listas em memória, relidas do Redis em segundo plano a cada refresh (Start),
+// para a requisição só ler o snapshot, sem lock e sem ida ao Redis
+type AccessListUsecase struct {
+	AccessListRepository access_list_entity.AccessListRepositoryInterface
+	refresh              time.Duration
+
+	// snapshot são as listas lidas por último, trocadas inteiras a cada refresh
+	snapshot atomic.Pointer[access_list_entity.AccessList]
+}
+
+type EntryInputDTO struct {
+	List  string `json:"list"`
+	Entry string `json:"entry"`
+}
+
+type AccessListOutputDTO struct {
+	Allow []string `json:"allow"`
+	Deny  []string `json:"deny"`
+}
+
+type AccessListUsecaseInterface interface {
+	Check(ip string) access_list_entity.Action
+	Add(ctx context.Context, input EntryInputDTO) *internal_error.InternalError
+	Remove(ctx context.Context, input EntryInputDTO) *internal_error.InternalError
+	List(ctx context.Context) (*AccessListOutputDTO, *internal_error.InternalError)
+}
+
+// NewAccessListUsecase relê as listas a cada refresh (0 usa DefaultRefreshInterval) depois do Start
+func NewAccessListUsecase(repository access_list_entity.AccessListRepositoryInterface, refresh time.Duration) *AccessListUsecase {
+	if refresh <= 0 {
+		refresh = DefaultRefreshInterval
+	}
+
+	return &AccessListUsecase{
+		AccessListRepository: repository,
+		refresh:              refresh,
+	}
+}
+
+// Check decide o IP pelo snapshot em memória, antes do primeiro Refresh nenhum IP está nas listas
+func (u *AccessListUsecase) Check(ip string) access_list_entity.Action {
+	accessList := u.snapshot.Load()
+	if accessList == nil {
+		return access_list_entity.None
+	}
+	return accessList.Check(ip)
+}
+
+// Start relê as listas a cada refresh até o contexto ser cancelado, fora do caminho das requisições
+func (u *AccessListUsecase) Start(ctx context.Context) {
+	ticker := time.NewTicker(u.refresh)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			u.Refresh(ctx)
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// Refresh relê as listas do Redis e troca o snapshot. Se o Redis falhar, continua com a última lista lida.
+func (u *AccessListUsecase) Refresh(ctx context.Context) *internal_error.InternalError {
+	accessList, err := u.AccessListRepository.FindAll(ctx)
+	if err != nil {
+		logger.Error("erro ao recarregar access list, mantendo a anterior", err)
+		return err
+	}
+
+	u.snapshot.Store(accessList)
+	return nil
+}
+
+func (u *AccessListUsecase) Add(ctx context.Context, input EntryInputDTO) *internal_error.InternalError {
+	list, entry, err := parseInput(input)
+	if err != nil {
+		return err
+	}
+
+	if err := u.AccessListRepository.Add(ctx, list, entry); err != nil {
+		return err
+	}
+	// a réplica que recebeu a alteração já passa a usá-la, as outras no próximo refresh
+	u.Refresh(ctx)
+	return nil
+}
+
+func (u *AccessListUsecase) Remove(ctx context.Context, input EntryInputDTO) *internal_error.InternalError {
+	list, entry, err := parseInput(input)
+	if err != nil {
+		return err
+	}
+
+	if err := u.AccessListRepository.Remove(ctx, list, entry); err != nil {
+		return err
+	}
+	u.Refresh(ctx)
+	return nil
+}
+
+// List devolve as listas direto do Redis, já normalizadas e em ordem
+func (u *AccessListUsecase) List(ctx context.Context) (*AccessListOutputDTO, *internal_error.InternalError) {
+	accessList, err := u.AccessListRepository.FindAll(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	output := &AccessListOutputDTO{Allow: []string{}, Deny: []string{}}
+	for _, prefix := range accessList.Allow {
+		output.Allow = append(output.Allow, prefix.String())
+	}
+	for _, prefix := range accessList.Deny {
+		output.Deny = append(output.Deny, prefix.String())
+	}
+	sort.Strings(output.Allow)
+	sort.Strings(output.Deny)
+
+	return output, nil
+}
+
+func parseInput(input EntryInputDTO) (access_list_entity.List, netip.Prefix, *internal_error.InternalError) {
+	list, err := access_list_entity.ParseList(input.List)
+	if err != nil {
+		return "", netip.Prefix{}, err
+	}
+
+	entry, err := access_list_entity.ParseEntry(input.Entry)
+	if err != nil {
+		return "", netip.Prefix{}, err
+	}
+	return list, entry, nil
+}
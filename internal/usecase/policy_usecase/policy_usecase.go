@@ -2,15 +2,28 @@ package policy_usecase
 
 import (
 	"context"
-	"time"
+	"net/http"
+	"sync"
 
+	"github.com/Higor-ViniciusDev/posgo_raterlimite/internal/entity/key_entity"
+	"github.com/Higor-ViniciusDev/posgo_raterlimite/internal/entity/limit_entity"
 	"github.com/Higor-ViniciusDev/posgo_raterlimite/internal/internal_error"
 	"github.com/Higor-ViniciusDev/posgo_raterlimite/internal/usecase/strategy_usecase"
 )
 
+// PolicyUsecase guarda as strategies em uso. Reload troca as strategies, as rotas, a cadeia e os fallbacks de uma vez,
+// sem parar as requisições; os contadores ficam no limiter e continuam valendo. A requisição lê tudo de um Snapshot.
 type PolicyUsecase struct {
+	mu sync.RWMutex
+
 	TokenStrategy RateLimitStrategy
 	IPStrategy    RateLimitStrategy
+	// Routes são os limites por rota da política, aplicados por cima da strategy
+	Routes limit_entity.RoutePolicies
+	// Chain são as strategies verificadas juntas em cada requisição, vazia usa só a do Resolver
+	Chain limit_entity.Chain
+	// TolkenFallback define por rota o que fazer com tolken inexistente ou expirado
+	TolkenFallback limit_entity.TolkenFallbackRules
 }
 
 type PolicyUsecaseInterface interface {
@@ -18,10 +31,21 @@ type PolicyUsecaseInterface interface {
 }
 
 type RateLimitStrategy interface {
-	GenerateKey(ctx context.Context, key string) (string, *internal_error.InternalError)
-	GetLimit() int64
-	GetTTL() time.Duration
-	GetPenaltyDuration() time.Duration
+	// GenerateKey valida o valor extraído e gera a key junto com os limites próprios dela (ex: o plano do tolken),
+	// numa única consulta por requisição. ExtraWindows são as janelas verificadas junto da principal (ex: 1000/h e 10000/dia)
+	GenerateKey(ctx context.Context, key string) (string, limit_entity.Plan, *internal_error.InternalError)
+	// GetAlgorithm define o algoritmo usado pelo rate limiter para esta strategy
+	GetAlgorithm() limit_entity.Algorithm
+	// GetBurst é a capacidade do bucket no TokenBucket (0 assume o próprio limite)
+	GetBurst() int64
+	// GetPenaltyPolicy escala a penalidade de quem repete a infração (sem degraus usa o Penalty do plano devolvido pelo GenerateKey)
+	GetPenaltyPolicy() limit_entity.PenaltyPolicy
+	// GetCost é quanto da cota a requisição consome (rotas pesadas custam mais que 1)
+	GetCost(r *http.Request) int64
+	// GetMaxInFlight é quantas requisições da key podem estar em andamento ao mesmo tempo (0 sem limite)
+	GetMaxInFlight() int64
+	// GetKeyExtractor diz de onde vem a identificação do cliente (API-KEY, Bearer, claim do JWT, IP...)
+	GetKeyExtractor() key_entity.KeyExtractor
 	SaveRequestInfo(ctx context.Context, key string) *internal_error.InternalError
 	GetInfoType() string
 }
@@ -31,6 +55,16 @@ type InputPolicyDTO struct {
 	Tolken string
 }
 
+// ResolvedStrategy é uma strategy da requisição com o valor extraído para ela
+type ResolvedStrategy struct {
+	// Name é o nome da strategy na cadeia (limit_entity.ChainIP ou ChainTolken)
+	Name     string
+	Strategy RateLimitStrategy
+	Key      string
+	// Limits são os limites da key, preenchidos junto com a key gerada
+	Limits limit_entity.Plan
+}
+
 func NewPolicyUsecase(ip *strategy_usecase.IPStrategyUsecase, tok *strategy_usecase.TokenStrategyUsecase) *PolicyUsecase {
 	return &PolicyUsecase{
 		IPStrategy:    ip,
@@ -38,10 +72,118 @@ func NewPolicyUsecase(ip *strategy_usecase.IPStrategyUsecase, tok *strategy_usec
 	}
 }
 
+// Snapshot é a política em uso em um instante, imutável: a requisição lê uma vez no começo e resolve
+// strategies, cadeia, rota e fallback pela mesma política, mesmo que um Reload aconteça no meio
+type Snapshot struct {
+	TokenStrategy  RateLimitStrategy
+	IPStrategy     RateLimitStrategy
+	Routes         limit_entity.RoutePolicies
+	Chain          limit_entity.Chain
+	TolkenFallback limit_entity.TolkenFallbackRules
+}
+
+// Snapshot devolve a política em uso, a única leitura sob o lock por requisição
+func (p *PolicyUsecase) Snapshot() *Snapshot {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	return &Snapshot{
+		TokenStrategy:  p.TokenStrategy,
+		IPStrategy:     p.IPStrategy,
+		Routes:         p.Routes,
+		Chain:          p.Chain,
+		TolkenFallback: p.TolkenFallback,
+	}
+}
+
+// Input monta o InputPolicyDTO com o extractor de cada strategy
+func (p *PolicyUsecase) Input(src key_entity.Source) InputPolicyDTO {
+	return p.Snapshot().Input(src)
+}
+
 // Decide qual strategy usar
 func (p *PolicyUsecase) Resolver(input InputPolicyDTO) (RateLimitStrategy, string) {
+	return p.Snapshot().Resolver(input)
+}
+
+// ResolveChain é o Snapshot.ResolveChain da política em uso
+func (p *PolicyUsecase) ResolveChain(input InputPolicyDTO) []ResolvedStrategy {
+	return p.Snapshot().ResolveChain(input)
+}
+
+// MatchRoute devolve a política da rota da requisição, false quando nenhuma casar
+func (p *PolicyUsecase) MatchRoute(method string, path string) (limit_entity.RoutePolicy, bool) {
+	return p.Snapshot().MatchRoute(method, path)
+}
+
+// MatchTolkenFallback devolve o fallback da rota para tolken inexistente ou expirado (reject quando nenhuma regra casar)
+func (p *PolicyUsecase) MatchTolkenFallback(method string, path string) limit_entity.TolkenFallback {
+	return p.Snapshot().MatchTolkenFallback(method, path)
+}
+
+// HasTolkenPlan diz se o plano existe na strategy de tolken em uso, acompanhando os reloads da política
+func (p *PolicyUsecase) HasTolkenPlan(name string) bool {
+	plans, ok := p.Snapshot().TokenStrategy.(interface{ HasPlan(name string) bool })
+	return ok && plans.HasPlan(name)
+}
+
+// Reload troca as strategies, as rotas, a cadeia e os fallbacks juntos: quem lê pelo Snapshot vê a política
+// antiga ou a nova inteira
+func (p *PolicyUsecase) Reload(ip RateLimitStrategy, tok RateLimitStrategy, routes limit_entity.RoutePolicies, chain limit_entity.Chain, fallback limit_entity.TolkenFallbackRules) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.IPStrategy = ip
+	p.TokenStrategy = tok
+	p.Routes = routes
+	p.Chain = chain
+	p.TolkenFallback = fallback
+}
+
+// Input monta o InputPolicyDTO com o extractor de cada strategy
+func (s *Snapshot) Input(src key_entity.Source) InputPolicyDTO {
+	return InputPolicyDTO{
+		Tolken: s.TokenStrategy.GetKeyExtractor().Extract(src),
+		IP:     s.IPStrategy.GetKeyExtractor().Extract(src),
+	}
+}
+
+// Resolver decide qual strategy usar: o tolken quando enviado, senão o IP
+func (s *Snapshot) Resolver(input InputPolicyDTO) (RateLimitStrategy, string) {
 	if input.Tolken != "" {
-		return p.TokenStrategy, input.Tolken
+		return s.TokenStrategy, input.Tolken
 	}
-	return p.IPStrategy, input.IP
+	return s.IPStrategy, input.IP
+}
+
+// ResolveChain devolve as strategies da cadeia que se aplicam ao input, na ordem da cadeia: o tolken
+// só entra quando o cliente enviou um. Sem cadeia, ou sem nenhuma strategy dela aplicável, vale o Resolver.
+func (s *Snapshot) ResolveChain(input InputPolicyDTO) []ResolvedStrategy {
+	var resolved []ResolvedStrategy
+	for _, name := range s.Chain {
+		switch {
+		case name == limit_entity.ChainTolken && input.Tolken != "":
+			resolved = append(resolved, ResolvedStrategy{Name: name, Strategy: s.TokenStrategy, Key: input.Tolken})
+		case name == limit_entity.ChainIP && input.IP != "":
+			resolved = append(resolved, ResolvedStrategy{Name: name, Strategy: s.IPStrategy, Key: input.IP})
+		}
+	}
+	if len(resolved) > 0 {
+		return resolved
+	}
+
+	if input.Tolken != "" {
+		return []ResolvedStrategy{{Name: limit_entity.ChainTolken, Strategy: s.TokenStrategy, Key: input.Tolken}}
+	}
+	return []ResolvedStrategy{{Name: limit_entity.ChainIP, Strategy: s.IPStrategy, Key: input.IP}}
+}
+
+// MatchRoute devolve a política da rota da requisição, false quando nenhuma casar
+func (s *Snapshot) MatchRoute(method string, path string) (limit_entity.RoutePolicy, bool) {
+	return s.Routes.Match(method, path)
+}
+
+// MatchTolkenFallback devolve o fallback da rota para tolken inexistente ou expirado (reject quando nenhuma regra casar)
+func (s *Snapshot) MatchTolkenFallback(method string, path string) limit_entity.TolkenFallback {
+	return s.TolkenFallback.Fallback(method, path)
 }
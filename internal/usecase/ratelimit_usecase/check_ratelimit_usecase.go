@@ -0,0 +1,156 @@
+package ratelimit_usecase
+
+import (
+	"context"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/Higor-ViniciusDev/posgo_raterlimite/configuration/logger"
+	"github.com/Higor-ViniciusDev/posgo_raterlimite/internal/internal_error"
+	"github.com/Higor-ViniciusDev/posgo_raterlimite/internal/ratelimiter"
+	"github.com/Higor-ViniciusDev/posgo_raterlimite/internal/usecase/policy_usecase"
+)
+
+// Quantidade máxima de keys em uma consulta em lote
+const MaxBatchSize = 100
+
+// Custo máximo de uma consulta, para um serviço não esgotar a cota de uma key de uma vez
+const MaxCost = 100
+
+// Prefixo das keys consultadas: os contadores da consulta ficam separados dos do middleware (ip:/token:),
+// assim quem consulta não consome nem penaliza a cota de um cliente da API
+const CheckNamespace = "check:"
+
+// Nomes aceitos em CheckInputDTO.Strategy
+const (
+	StrategyIP     = "ip"
+	StrategyTolken = "tolken"
+)
+
+// RateLimitUsecase responde "a key pode seguir?" para serviços que não usam o middleware,
+// com a mesma policy e o mesmo limiter.
+type RateLimitUsecase struct {
+	Policy  *policy_usecase.PolicyUsecase
+	Limiter ratelimiter.Limiter
+}
+
+type RateLimitUsecaseInterface interface {
+	Check(ctx context.Context, input CheckInputDTO) (*CheckOutputDTO, *internal_error.InternalError)
+	CheckBatch(ctx context.Context, inputs []CheckInputDTO) ([]CheckOutputDTO, *internal_error.InternalError)
+}
+
+type CheckInputDTO struct {
+	// Key é o IP ou o tolken, conforme a Strategy
+	Key      string `json:"key"`
+	Strategy string `json:"strategy"`
+	// Cost é quanto da cota a consulta consome (0 conta como 1, no máximo MaxCost)
+	Cost int64 `json:"cost"`
+}
+
+type CheckOutputDTO struct {
+	Key       string    `json:"key"`
+	Strategy  string    `json:"strategy"`
+	Allowed   bool      `json:"allowed"`
+	Limit     int64     `json:"limit"`
+	Remaining int64     `json:"remaining"`
+	ResetAt   time.Time `json:"reset_at"`
+	// Window é a janela da decisão em segundos (a que bloqueou ou a com menos cota restante)
+	Window int64 `json:"window"`
+	// RetryAfter são os segundos até a key voltar a ser aceita, só quando bloqueada
+	RetryAfter int64 `json:"retry_after,omitempty"`
+	// Error explica por que um item do lote não pôde ser decidido
+	Error string `json:"error,omitempty"`
+}
+
+func NewRateLimitUsecase(policy *policy_usecase.PolicyUsecase, limiter ratelimiter.Limiter) *RateLimitUsecase {
+	return &RateLimitUsecase{
+		Policy:  policy,
+		Limiter: limiter,
+	}
+}
+
+// Check passa a key pela policy e pelo limiter e devolve a decisão.
+// Só a strategy informada é verificada, com os limites dela (e do plano do tolken): a consulta não tem
+// método e caminho nem as outras keys da requisição, então POLICY_CHAIN e ROUTE_POLICIES não se aplicam.
+func (u *RateLimitUsecase) Check(ctx context.Context, input CheckInputDTO) (*CheckOutputDTO, *internal_error.InternalError) {
+	var policyInput policy_usecase.InputPolicyDTO
+
+	switch strings.ToLower(strings.TrimSpace(input.Strategy)) {
+	case StrategyIP:
+		policyInput.IP = input.Key
+	case StrategyTolken, "token":
+		policyInput.Tolken = input.Key
+	default:
+		return nil, internal_error.NewBadRequestError("strategy invalid: " + input.Strategy)
+	}
+
+	if input.Cost < 0 || input.Cost > MaxCost {
+		return nil, internal_error.NewBadRequestError("cost invalid")
+	}
+
+	strategy, key := u.Policy.Resolver(policyInput)
+
+	key, limits, err := strategy.GenerateKey(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	key = CheckNamespace + key
+
+	rules := ratelimiter.NewRules(strategy, limits)
+	rules.Cost = input.Cost
+
+	decision, err := u.Limiter.Allow(ctx, key, rules)
+	if err != nil {
+		return nil, err
+	}
+
+	if decision.Allowed {
+		//Mesmo requisito do middleware: apenas as liberadas são salvas
+		go saveRequestInfo(strategy, key)
+	}
+
+	return &CheckOutputDTO{
+		Key:        input.Key,
+		Strategy:   strings.ToLower(strategy.GetInfoType()),
+		Allowed:    decision.Allowed,
+		Limit:      decision.Limit,
+		Remaining:  decision.Remaining,
+		ResetAt:    decision.ResetAt,
+		Window:     int64(math.Ceil(decision.Window.Seconds())),
+		RetryAfter: int64(math.Ceil(decision.RetryAfter().Seconds())),
+	}, nil
+}
+
+// CheckBatch decide várias keys em uma chamada. Cada item é decidido de forma independente:
+// uma key inválida volta com Error preenchido sem impedir as demais.
+func (u *RateLimitUsecase) CheckBatch(ctx context.Context, inputs []CheckInputDTO) ([]CheckOutputDTO, *internal_error.InternalError) {
+	if len(inputs) == 0 {
+		return nil, internal_error.NewBadRequestError("checks is empty")
+	}
+	if len(inputs) > MaxBatchSize {
+		return nil, internal_error.NewBadRequestError("too many checks in batch")
+	}
+
+	outputs := make([]CheckOutputDTO, len(inputs))
+	for i, input := range inputs {
+		output, err := u.Check(ctx, input)
+		if err != nil {
+			// só erro da própria key fica no item, falha do limiter vale para o lote inteiro
+			if err.Err != "bad_request" && err.Err != "not_found" {
+				return nil, err
+			}
+			outputs[i] = CheckOutputDTO{Key: input.Key, Strategy: input.Strategy, Error: err.Message}
+			continue
+		}
+		outputs[i] = *output
+	}
+
+	return outputs, nil
+}
+
+func saveRequestInfo(strategy policy_usecase.RateLimitStrategy, key string) {
+	if err := strategy.SaveRequestInfo(context.Background(), key); err != nil {
+		logger.Error("erro ao salvar request info", err)
+	}
+}
@@ -0,0 +1,218 @@
+package ratelimit_usecase
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/Higor-ViniciusDev/posgo_raterlimite/configuration/policy"
+	"github.com/Higor-ViniciusDev/posgo_raterlimite/internal/entity/limit_entity"
+	"github.com/Higor-ViniciusDev/posgo_raterlimite/internal/entity/tolken_entity"
+	"github.com/Higor-ViniciusDev/posgo_raterlimite/internal/infra/repository"
+	"github.com/Higor-ViniciusDev/posgo_raterlimite/internal/ratelimiter"
+	"github.com/Higor-ViniciusDev/posgo_raterlimite/internal/usecase/policy_usecase"
+	"github.com/Higor-ViniciusDev/posgo_raterlimite/internal/usecase/strategy_usecase"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+// envLimits lê os limites da strategy do env atual, como a inicialização do serviço
+func envLimits(t *testing.T, suffix string) limit_entity.StrategyLimits {
+	t.Helper()
+
+	strategy, err := policy.StrategyFromEnv(suffix)
+	if err != nil {
+		t.Fatalf("variaveis invalidas para strategy %s: %v", suffix, err)
+	}
+	return strategy.Limits()
+}
+
+func newTestUsecase(t *testing.T) *RateLimitUsecase {
+	t.Helper()
+
+	// a janela fixa recusa a requisição que atinge o limite: o IP admite 4
+	t.Setenv("REQUEST_PER_SECOND_IP", "5")
+	t.Setenv("TLL_KEY_IP", "10")
+	t.Setenv("TIME_UNLOCKED_NEW_REQUEST_IP", "0")
+
+	mr, err := miniredis.Run()
+	if err != nil {
+		t.Fatalf("erro ao iniciar miniredis: %v", err)
+	}
+	t.Cleanup(mr.Close)
+
+	redisClient := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	requestInfoRepository := repository.NewRequestInfoRepository(redisClient)
+
+	policy := &policy_usecase.PolicyUsecase{
+		TokenStrategy: strategy_usecase.NewTokenStrategyUsecaseWithLimits(envLimits(t, policy.EnvTolken), repository.NewTolkenDB(redisClient), requestInfoRepository),
+		IPStrategy:    strategy_usecase.NewIPStrategyUsecaseWithLimits(envLimits(t, policy.EnvIP), requestInfoRepository),
+	}
+
+	return NewRateLimitUsecase(policy, ratelimiter.NewLocalLimiter(0))
+}
+
+func TestRateLimitUsecase_CheckConsumesCost(t *testing.T) {
+	u := newTestUsecase(t)
+	ctx := context.Background()
+
+	output, err := u.Check(ctx, CheckInputDTO{Key: "10.0.0.5", Strategy: "ip", Cost: 3})
+	if err != nil {
+		t.Fatalf("erro inesperado: %v", err)
+	}
+	if !output.Allowed || output.Limit != 5 || output.Remaining != 1 || output.Strategy != "ip" {
+		t.Fatalf("decisão inesperada: %+v", output)
+	}
+
+	output, err = u.Check(ctx, CheckInputDTO{Key: "10.0.0.5", Strategy: "ip", Cost: 3})
+	if err != nil {
+		t.Fatalf("erro inesperado: %v", err)
+	}
+	if output.Allowed || output.RetryAfter <= 0 {
+		t.Fatalf("custo acima do restante deveria ser bloqueado com retry after, recebido %+v", output)
+	}
+
+	if _, err := u.Check(ctx, CheckInputDTO{Key: "10.0.0.5", Strategy: "cookie"}); err == nil || err.Err != "bad_request" {
+		t.Fatalf("strategy desconhecida deveria ser bad request, recebido %v", err)
+	}
+	if _, err := u.Check(ctx, CheckInputDTO{Key: "10.0.0.8", Strategy: "ip", Cost: MaxCost + 1}); err == nil || err.Err != "bad_request" {
+		t.Fatalf("custo acima do máximo deveria ser bad request, recebido %v", err)
+	}
+
+	// a consulta usa o próprio namespace: a cota do middleware para o mesmo IP continua intacta
+	decision, err := u.Limiter.Allow(ctx, "ip:10.0.0.5", ratelimiter.Rules{Limit: 5, TTL: 10 * time.Second})
+	if err != nil || !decision.Allowed || decision.Remaining != 3 {
+		t.Fatalf("a consulta não deveria consumir a cota do middleware: %+v %v", decision, err)
+	}
+}
+
+func TestRateLimitUsecase_CheckBatchDecidesEachKey(t *testing.T) {
+	u := newTestUsecase(t)
+
+	outputs, err := u.CheckBatch(context.Background(), []CheckInputDTO{
+		{Key: "10.0.0.6", Strategy: "ip", Cost: 4},
+		{Key: "10.0.0.6", Strategy: "ip"},
+		{Key: "10.0.0.7", Strategy: "ip"},
+		{Key: "", Strategy: "ip"},
+	})
+	if err != nil {
+		t.Fatalf("erro inesperado: %v", err)
+	}
+
+	if len(outputs) != 4 {
+		t.Fatalf("esperado um resultado por item, recebido %d", len(outputs))
+	}
+	if !outputs[0].Allowed || outputs[1].Allowed || !outputs[2].Allowed {
+		t.Fatalf("cada key deveria ter a própria cota: %+v", outputs)
+	}
+	if outputs[3].Error == "" || outputs[3].Allowed {
+		t.Fatalf("key inválida deveria voltar com erro no item: %+v", outputs[3])
+	}
+
+	if _, err := u.CheckBatch(context.Background(), make([]CheckInputDTO, MaxBatchSize+1)); err == nil {
+		t.Fatal("lote acima do máximo deveria ser recusado")
+	}
+}
+
+func TestRateLimitUsecase_CheckUsesTolkenPlan(t *testing.T) {
+	u := newTestUsecase(t)
+	ctx := context.Background()
+
+	mr, err := miniredis.Run()
+	if err != nil {
+		t.Fatalf("erro ao iniciar miniredis: %v", err)
+	}
+	t.Cleanup(mr.Close)
+
+	redisClient := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	tolkenRepository := repository.NewTolkenDB(redisClient)
+
+	u.Policy.TokenStrategy = strategy_usecase.NewTokenStrategyUsecaseWithLimits(limit_entity.StrategyLimits{
+		Limit: 10,
+		TTL:   time.Second,
+		Plans: map[string]limit_entity.Plan{"pro": {Limit: 100}},
+	}, tolkenRepository, repository.NewRequestInfoRepository(redisClient))
+
+	tolkens := []*tolken_entity.Tolken{
+		{TolkenString: "sem-plano"},
+		{TolkenString: "pro", Plan: "pro"},
+		{TolkenString: "pro-explicito", Plan: "pro", Limit: 3},
+		{TolkenString: "desconhecido", Plan: "gold"},
+	}
+	expected := map[string]int64{"sem-plano": 10, "pro": 100, "pro-explicito": 3, "desconhecido": 10}
+
+	for _, tolken := range tolkens {
+		if err := tolkenRepository.Save(ctx, tolken); err != nil {
+			t.Fatalf("erro ao salvar tolken: %v", err)
+		}
+
+		name := tolken.TolkenString
+		output, err := u.Check(ctx, CheckInputDTO{Key: name, Strategy: "tolken"})
+		if err != nil {
+			t.Fatalf("erro inesperado: %v", err)
+		}
+		if !output.Allowed || output.Limit != expected[name] {
+			t.Fatalf("tolken %s deveria ter limite %d, recebido %+v", name, expected[name], output)
+		}
+	}
+}
+
+func TestRateLimitUsecase_CheckFindsTolkenOnce(t *testing.T) {
+	u := newTestUsecase(t)
+
+	// só o repositório de tolkens usa este redis, o request info vai para outro
+	mr, err := miniredis.Run()
+	if err != nil {
+		t.Fatalf("erro ao iniciar miniredis: %v", err)
+	}
+	t.Cleanup(mr.Close)
+
+	tolkenRepository := repository.NewTolkenDB(redis.NewClient(&redis.Options{Addr: mr.Addr()}))
+	u.Policy.TokenStrategy = strategy_usecase.NewTokenStrategyUsecaseWithLimits(limit_entity.StrategyLimits{
+		Limit: 10,
+		TTL:   time.Second,
+		Plans: map[string]limit_entity.Plan{"pro": {Limit: 100}},
+	}, tolkenRepository, u.Policy.IPStrategy.(*strategy_usecase.IPStrategyUsecase).RequestRepository)
+
+	if err := tolkenRepository.Save(context.Background(), &tolken_entity.Tolken{TolkenString: "pro", Plan: "pro"}); err != nil {
+		t.Fatalf("erro ao salvar tolken: %v", err)
+	}
+	before := mr.CommandCount()
+
+	output, checkErr := u.Check(context.Background(), CheckInputDTO{Key: "pro", Strategy: "tolken"})
+	if checkErr != nil || output.Limit != 100 {
+		t.Fatalf("tolken pro deveria ter limite 100: %+v %v", output, checkErr)
+	}
+	if commands := mr.CommandCount() - before; commands != 1 {
+		t.Fatalf("o tolken e o plano deveriam vir de uma única consulta, recebidas %d", commands)
+	}
+}
+
+func TestRateLimitUsecase_CheckAggregatesIPPrefix(t *testing.T) {
+	t.Setenv("IPV6_PREFIX_IP", "64")
+	u := newTestUsecase(t)
+	ctx := context.Background()
+
+	// trocar de endereço dentro da mesma /64 não escapa do limite
+	for i := 1; i <= 4; i++ {
+		output, err := u.Check(ctx, CheckInputDTO{Key: fmt.Sprintf("2001:db8:1:2::%x", i), Strategy: "ip"})
+		if err != nil || !output.Allowed {
+			t.Fatalf("requisição %d dentro do limite deveria passar: %+v %v", i, output, err)
+		}
+	}
+	if output, _ := u.Check(ctx, CheckInputDTO{Key: "2001:db8:1:2::ff", Strategy: "ip"}); output.Allowed {
+		t.Fatalf("a /64 inteira deveria dividir o contador: %+v", output)
+	}
+	if output, _ := u.Check(ctx, CheckInputDTO{Key: "2001:db8:1:3::1", Strategy: "ip"}); !output.Allowed {
+		t.Fatalf("outra /64 deveria ter a própria cota: %+v", output)
+	}
+
+	// IPv4 e o mesmo IPv4 mapeado em IPv6 dividem o contador
+	if output, _ := u.Check(ctx, CheckInputDTO{Key: "203.0.113.9", Strategy: "ip", Cost: 4}); !output.Allowed {
+		t.Fatalf("IPv4 dentro do limite deveria passar: %+v", output)
+	}
+	if output, _ := u.Check(ctx, CheckInputDTO{Key: "::ffff:203.0.113.9", Strategy: "ip"}); output.Allowed {
+		t.Fatalf("IPv4 mapeado deveria usar o contador do IPv4: %+v", output)
+	}
+}
@@ -2,10 +2,11 @@ package strategy_usecase
 
 import (
 	"context"
-	"os"
-	"strconv"
+	"net/http"
 	"time"
 
+	"github.com/Higor-ViniciusDev/posgo_raterlimite/internal/entity/key_entity"
+	"github.com/Higor-ViniciusDev/posgo_raterlimite/internal/entity/limit_entity"
 	"github.com/Higor-ViniciusDev/posgo_raterlimite/internal/entity/request_info_entity"
 	"github.com/Higor-ViniciusDev/posgo_raterlimite/internal/internal_error"
 )
@@ -14,46 +15,77 @@ type IPStrategyUsecase struct {
 	limitIP           int64
 	window            time.Duration
 	PenalityBlock     time.Duration
+	algorithm         limit_entity.Algorithm
+	burst             int64
+	penaltyPolicy     limit_entity.PenaltyPolicy
+	costRules         limit_entity.CostRules
+	maxInFlight       int64
+	extraWindows      []limit_entity.Window
+	ipPrefix          limit_entity.IPPrefix
+	keyExtractor      key_entity.KeyExtractor
 	RequestRepository request_info_entity.RequestRepository
 }
 
-func NewIPStrategyUsecase(requestRepository request_info_entity.RequestRepository) *IPStrategyUsecase {
-
-	limitStr := os.Getenv("REQUEST_PER_SECOND_IP")
-	ttlStr := os.Getenv("TLL_KEY_IP")
-	timePenalityStr := os.Getenv("TIME_UNLOCKED_NEW_REQUEST_IP")
-
-	limit, _ := strconv.ParseInt(limitStr, 10, 64)
-	ttl, _ := strconv.Atoi(ttlStr)
-	penality, _ := strconv.Atoi(timePenalityStr)
-
+// NewIPStrategyUsecaseWithLimits cria a strategy com os limites da política já validada
+func NewIPStrategyUsecaseWithLimits(limits limit_entity.StrategyLimits, requestRepository request_info_entity.RequestRepository) *IPStrategyUsecase {
 	return &IPStrategyUsecase{
-		limitIP:           limit,
-		window:            time.Duration(ttl) * time.Second,
-		PenalityBlock:     time.Duration(penality) * time.Second,
+		limitIP:           limits.Limit,
+		window:            limits.TTL,
+		PenalityBlock:     limits.Penalty,
+		algorithm:         limits.Algorithm,
+		burst:             limits.Burst,
+		penaltyPolicy:     limits.PenaltyPolicy,
+		costRules:         limits.CostRules,
+		maxInFlight:       limits.MaxInFlight,
+		extraWindows:      limits.ExtraWindows,
+		ipPrefix:          limits.IPPrefix,
+		keyExtractor:      keyExtractor(limits.KeyExtractor, key_entity.DefaultIPExtractor),
 		RequestRepository: requestRepository,
 	}
 }
 
-func (s *IPStrategyUsecase) GenerateKey(ctx context.Context, key string) (string, *internal_error.InternalError) {
+func (s *IPStrategyUsecase) GenerateKey(ctx context.Context, key string) (string, limit_entity.Plan, *internal_error.InternalError) {
 
 	if key == "" {
-		return "", internal_error.NewBadRequestError("key invalid")
+		return "", limit_entity.Plan{}, internal_error.NewBadRequestError("key invalid")
+	}
+
+	// IPv4 mapeado vira IPv4 e, com prefixo configurado, a rede inteira divide a mesma key (ex: ip:2001:db8:1:2::/64)
+	return "ip:" + s.ipPrefix.Key(key), s.limits(), nil
+}
+
+// limits são os limites da strategy, iguais para todos os IPs
+func (s *IPStrategyUsecase) limits() limit_entity.Plan {
+	return limit_entity.Plan{
+		Limit:        s.limitIP,
+		TTL:          s.window,
+		Penalty:      s.PenalityBlock,
+		ExtraWindows: s.extraWindows,
 	}
+}
 
-	return "ip:" + key, nil
+func (s *IPStrategyUsecase) GetAlgorithm() limit_entity.Algorithm {
+	return s.algorithm
 }
 
-func (s *IPStrategyUsecase) GetLimit() int64 {
-	return s.limitIP
+func (s *IPStrategyUsecase) GetBurst() int64 {
+	return s.burst
 }
 
-func (s *IPStrategyUsecase) GetTTL() time.Duration {
-	return s.window
+func (s *IPStrategyUsecase) GetPenaltyPolicy() limit_entity.PenaltyPolicy {
+	return s.penaltyPolicy
 }
 
-func (s *IPStrategyUsecase) GetPenaltyDuration() time.Duration {
-	return s.PenalityBlock
+func (s *IPStrategyUsecase) GetCost(r *http.Request) int64 {
+	return s.costRules.Cost(r.Method, r.URL.Path)
+}
+
+func (s *IPStrategyUsecase) GetMaxInFlight() int64 {
+	return s.maxInFlight
+}
+
+func (s *IPStrategyUsecase) GetKeyExtractor() key_entity.KeyExtractor {
+	return s.keyExtractor
 }
 
 func (s *IPStrategyUsecase) GetInfoType() string {
@@ -64,3 +96,11 @@ func (s *IPStrategyUsecase) SaveRequestInfo(ctx context.Context, key string) *in
 	newRequest := request_info_entity.NewRequestInfo(key, request_info_entity.Active, s.GetInfoType())
 	return s.RequestRepository.Save(ctx, key, newRequest.Status, newRequest.FontePolicy)
 }
+
+// keyExtractor usa o extractor da política ou, sem ele, o padrão da strategy
+func keyExtractor(configured key_entity.KeyExtractor, fallback key_entity.KeyExtractor) key_entity.KeyExtractor {
+	if configured == nil {
+		return fallback
+	}
+	return configured
+}
@@ -2,13 +2,16 @@ package strategy_usecase
 
 import (
 	"context"
-	"os"
-	"strconv"
+	"net/http"
 	"time"
 
+	"github.com/Higor-ViniciusDev/posgo_raterlimite/configuration/logger"
+	"github.com/Higor-ViniciusDev/posgo_raterlimite/internal/entity/key_entity"
+	"github.com/Higor-ViniciusDev/posgo_raterlimite/internal/entity/limit_entity"
 	"github.com/Higor-ViniciusDev/posgo_raterlimite/internal/entity/request_info_entity"
 	"github.com/Higor-ViniciusDev/posgo_raterlimite/internal/entity/tolken_entity"
 	"github.com/Higor-ViniciusDev/posgo_raterlimite/internal/internal_error"
+	"go.uber.org/zap"
 )
 
 type TokenStrategyUsecase struct {
@@ -18,50 +21,109 @@ type TokenStrategyUsecase struct {
 	limitTok      int64
 	window        time.Duration
 	PenalityBlock time.Duration
+	algorithm     limit_entity.Algorithm
+	burst         int64
+	penaltyPolicy limit_entity.PenaltyPolicy
+	costRules     limit_entity.CostRules
+	maxInFlight   int64
+	extraWindows  []limit_entity.Window
+	// plans são os limites de cada plano, aplicados aos tolkens salvos com o plano
+	plans        map[string]limit_entity.Plan
+	keyExtractor key_entity.KeyExtractor
 }
 
-func NewTokenStrategyUsecase(tokenRepo tolken_entity.TolkenRepositoryInterface, requestRepository request_info_entity.RequestRepository) *TokenStrategyUsecase {
-	limitStr := os.Getenv("REQUEST_PER_SECOND_TOLKEN")
-	ttlStr := os.Getenv("TLL_KEY_TOLKEN")
-	penaltyStr := os.Getenv("TIME_UNLOCKED_NEW_REQUEST_TOLKEN")
-
-	limit, _ := strconv.ParseInt(limitStr, 10, 64)
-	ttl, _ := strconv.Atoi(ttlStr)
-	penalty, _ := strconv.Atoi(penaltyStr)
-
+// NewTokenStrategyUsecaseWithLimits cria a strategy com os limites da política já validada
+func NewTokenStrategyUsecaseWithLimits(limits limit_entity.StrategyLimits, tokenRepo tolken_entity.TolkenRepositoryInterface, requestRepository request_info_entity.RequestRepository) *TokenStrategyUsecase {
 	return &TokenStrategyUsecase{
 		TokenRepository:   tokenRepo,
 		RequestRepository: requestRepository,
-		limitTok:          limit,
-		window:            time.Duration(ttl) * time.Second,
-		PenalityBlock:     time.Duration(penalty) * time.Second,
+		limitTok:          limits.Limit,
+		window:            limits.TTL,
+		PenalityBlock:     limits.Penalty,
+		algorithm:         limits.Algorithm,
+		burst:             limits.Burst,
+		penaltyPolicy:     limits.PenaltyPolicy,
+		costRules:         limits.CostRules,
+		maxInFlight:       limits.MaxInFlight,
+		extraWindows:      limits.ExtraWindows,
+		plans:             limits.Plans,
+		keyExtractor:      keyExtractor(limits.KeyExtractor, key_entity.DefaultTolkenExtractor),
 	}
 }
 
-func (s *TokenStrategyUsecase) GenerateKey(ctx context.Context, key string) (string, *internal_error.InternalError) {
+func (s *TokenStrategyUsecase) GenerateKey(ctx context.Context, key string) (string, limit_entity.Plan, *internal_error.InternalError) {
 
 	if key == "" {
-		return "", internal_error.NewBadRequestError("tolken invalid")
+		return "", limit_entity.Plan{}, internal_error.NewBadRequestError("tolken invalid")
+	}
+
+	// uma única busca valida o tolken e traz o plano dele; a claim de um JWT assinado já vem validada
+	// e, sem registro no redis, fica com os limites da strategy
+	tolken, err := s.TokenRepository.FindTolken(ctx, key)
+	if err != nil {
+		if !key_entity.Authenticates(s.keyExtractor) {
+			return "", limit_entity.Plan{}, internal_error.NewBadRequestError("tolken not found")
+		}
+		tolken = nil
+	}
+
+	return "token:" + key, s.resolvePlan(tolken), nil
+}
+
+// resolvePlan monta os limites do tolken: os da strategy, por cima o plano do tolken e por cima
+// os valores explícitos salvos nele. Tolken sem registro ou com plano desconhecido fica com os da strategy.
+func (s *TokenStrategyUsecase) resolvePlan(tolken *tolken_entity.Tolken) limit_entity.Plan {
+	limits := limit_entity.Plan{
+		Limit:        s.limitTok,
+		TTL:          s.window,
+		Penalty:      s.PenalityBlock,
+		ExtraWindows: s.extraWindows,
+	}
+
+	if tolken == nil {
+		return limits
 	}
 
-	// valida se token existe no redis (sem regras complexas)
-	if !s.TokenRepository.ValidateTolken(ctx, key) {
-		return "", internal_error.NewBadRequestError("tolken not found")
+	if tolken.Plan != "" {
+		plan, ok := s.plans[tolken.Plan]
+		if ok {
+			limits = plan.Apply(limits)
+		} else {
+			logger.Info("plano do tolken desconhecido, usando os limites da strategy", zap.String("plan", tolken.Plan))
+		}
 	}
 
-	return "token:" + key, nil
+	return limit_entity.Plan{Limit: tolken.Limit, TTL: tolken.Window, Penalty: tolken.Penalty}.Apply(limits)
+}
+
+// HasPlan diz se o plano está configurado na strategy
+func (s *TokenStrategyUsecase) HasPlan(name string) bool {
+	_, ok := s.plans[name]
+	return ok
+}
+
+func (s *TokenStrategyUsecase) GetAlgorithm() limit_entity.Algorithm {
+	return s.algorithm
+}
+
+func (s *TokenStrategyUsecase) GetBurst() int64 {
+	return s.burst
+}
+
+func (s *TokenStrategyUsecase) GetPenaltyPolicy() limit_entity.PenaltyPolicy {
+	return s.penaltyPolicy
 }
 
-func (s *TokenStrategyUsecase) GetLimit() int64 {
-	return s.limitTok
+func (s *TokenStrategyUsecase) GetCost(r *http.Request) int64 {
+	return s.costRules.Cost(r.Method, r.URL.Path)
 }
 
-func (s *TokenStrategyUsecase) GetTTL() time.Duration {
-	return s.window
+func (s *TokenStrategyUsecase) GetMaxInFlight() int64 {
+	return s.maxInFlight
 }
 
-func (s *TokenStrategyUsecase) GetPenaltyDuration() time.Duration {
-	return s.PenalityBlock
+func (s *TokenStrategyUsecase) GetKeyExtractor() key_entity.KeyExtractor {
+	return s.keyExtractor
 }
 
 func (s *TokenStrategyUsecase) GetInfoType() string {
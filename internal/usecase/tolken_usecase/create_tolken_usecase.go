@@ -2,6 +2,7 @@ package tolken_usecase
 
 import (
 	"context"
+	"strings"
 	"time"
 
 	"github.com/Higor-ViniciusDev/posgo_raterlimite/internal/entity/tolken_entity"
@@ -12,27 +13,63 @@ import (
 type TolkenUsecase struct {
 	TolkenRepository tolken_entity.TolkenRepositoryInterface
 	Expirer          expire_usecase.ExpirerInterface
+	// Plans são os planos da política em uso, o tolken só pode ser criado com um deles
+	Plans PlanCatalog
+}
+
+// PlanCatalog diz se o plano existe na política em uso
+type PlanCatalog interface {
+	HasTolkenPlan(name string) bool
 }
 
 type TolkenOutputDTO struct {
 	Tolken string `json:"tolken"`
 }
 
-func NewTolkenUsecase(tolkenRepository tolken_entity.TolkenRepositoryInterface, expire expire_usecase.ExpirerInterface) *TolkenUsecase {
+// CreateTolkenInputDTO define o plano do tolken ou os limites explícitos dele, tudo opcional.
+// Window e Penalty são em segundos, como no .env.
+type CreateTolkenInputDTO struct {
+	Plan    string `json:"plan"`
+	Limit   int64  `json:"limit"`
+	Window  int64  `json:"window"`
+	Penalty int64  `json:"penalty"`
+}
+
+// HasOverrides diz se o tolken pede plano ou limites próprios, o que só a administração pode definir
+func (input CreateTolkenInputDTO) HasOverrides() bool {
+	return strings.TrimSpace(input.Plan) != "" || input.Limit != 0 || input.Window != 0 || input.Penalty != 0
+}
+
+func NewTolkenUsecase(tolkenRepository tolken_entity.TolkenRepositoryInterface, expire expire_usecase.ExpirerInterface, plans PlanCatalog) *TolkenUsecase {
 	return &TolkenUsecase{
 		TolkenRepository: tolkenRepository,
 		Expirer:          expire,
+		Plans:            plans,
 	}
 }
 
 type TolkenUsecaseInterface interface {
-	CreateTolken(ctx context.Context) (*TolkenOutputDTO, *internal_error.InternalError)
+	CreateTolken(ctx context.Context, input CreateTolkenInputDTO) (*TolkenOutputDTO, *internal_error.InternalError)
 }
 
-func (tl *TolkenUsecase) CreateTolken(ctx context.Context) (*TolkenOutputDTO, *internal_error.InternalError) {
+func (tl *TolkenUsecase) CreateTolken(ctx context.Context, input CreateTolkenInputDTO) (*TolkenOutputDTO, *internal_error.InternalError) {
+	if input.Limit < 0 || input.Window < 0 || input.Penalty < 0 {
+		return nil, internal_error.NewBadRequestError("limit, window and penalty must not be negative")
+	}
+
+	plan := strings.TrimSpace(input.Plan)
+	if plan != "" && (tl.Plans == nil || !tl.Plans.HasTolkenPlan(plan)) {
+		return nil, internal_error.NewBadRequestError("plan unknown: " + plan)
+	}
+
 	tolkenEntity := tolken_entity.NewTolken()
 	tolkenString := tolkenEntity.GetTolkenString()
 
+	tolkenEntity.Plan = plan
+	tolkenEntity.Limit = input.Limit
+	tolkenEntity.Window = time.Duration(input.Window) * time.Second
+	tolkenEntity.Penalty = time.Duration(input.Penalty) * time.Second
+
 	err := tl.TolkenRepository.Save(ctx, tolkenEntity)
 
 	if err != nil {